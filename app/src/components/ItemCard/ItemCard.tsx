@@ -14,6 +14,7 @@ import {
 } from '@material-ui/core';
 import { useHistory } from 'react-router-dom'
 import { formatDistanceToNow, parseISO } from 'date-fns'
+import { formatPrice } from '../../utils/currency'
 
 const useStyles = makeStyles((theme: Theme) =>
   createStyles({
@@ -65,6 +66,7 @@ export default function ItemCard(props) {
   const id = props.id || ''
   const updated = parseISO(props.time) || 'Unknown'
   const price: number = props.price || 0
+  const currency = props.currency || 'VND'
   const onClickStore = () => {
     window.open(url)
   }
@@ -82,7 +84,7 @@ export default function ItemCard(props) {
         <div>
           <CardContent className={classes.content}>
             <Typography variant="h3" className={classes.text}>
-              {price.toLocaleString()}
+              {formatPrice(price, currency)}
             </Typography>
             <Typography
               variant="h6"
@@ -90,7 +92,7 @@ export default function ItemCard(props) {
               className={classes.vnd}
               align="right"
             >
-              VND
+              {currency}
             </Typography>
           </CardContent>
         </div>
@@ -30,6 +30,7 @@ import { useParams } from 'react-router-dom'
 import { getItem, getItemPrices } from '../../api/item'
 import { ItemPrice, ItemWithPrice } from '../../api/models'
 import empty from '../../images/empty.jpg'
+import { formatPrice } from '../../utils/currency'
 import { line, curveStepAfter } from 'd3-shape'
 
 const useStyles = makeStyles((theme: Theme) =>
@@ -177,6 +178,7 @@ export default function ItemPage() {
   let description = item?.description || 'No description'
   let updated = item?.time ? parseISO(item?.time) : new Date()
   let price: number = item?.price || 0
+  let currency = item?.currency || 'VND'
   const onClickStore = () => {
     window.open(url)
   }
@@ -187,11 +189,12 @@ export default function ItemPage() {
     url = item?.url || '/'
     updated = item?.time ? parseISO(item?.time) : new Date()
     price = item?.price || 0
+    currency = item?.currency || 'VND'
     description = item?.description || 'No description'
   }, [item])
 
   const TooltipContent = ({ targetItem }) => (
-    <div>{itemPrices[targetItem.point].price.toLocaleString()} VND</div>
+    <div>{formatPrice(itemPrices[targetItem.point].price, currency)} {currency}</div>
   )
 
   const ArgumentLabel = ({ x, y, dy, text, textAnchor }) => {
@@ -230,7 +233,7 @@ export default function ItemPage() {
                 </Typography>
                 <div className={classes.content}>
                   <Typography variant="h3" className={classes.text}>
-                    {price.toLocaleString()}
+                    {formatPrice(price, currency)}
                   </Typography>
                   <Typography
                     variant="h6"
@@ -238,7 +241,7 @@ export default function ItemPage() {
                     className={classes.vnd}
                     align="right"
                   >
-                    VND
+                    {currency}
                   </Typography>
                 </div>
               </CardContent>
@@ -0,0 +1,17 @@
+// Prices are sent in the minor unit of their currency, e.g. cents for USD and đồng for VND
+export function minorUnits(currency: string) {
+  try {
+    return new Intl.NumberFormat(undefined, { style: 'currency', currency })
+      .resolvedOptions().maximumFractionDigits
+  } catch (_) {
+    return 2
+  }
+}
+
+export function formatPrice(price: number, currency: string) {
+  const digits = minorUnits(currency)
+  return (price / 10 ** digits).toLocaleString(undefined, {
+    minimumFractionDigits: digits,
+    maximumFractionDigits: digits,
+  })
+}
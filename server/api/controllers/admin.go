@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/api/payloads"
+	"github.com/UN0wen/pricewatch-vn/server/services"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// recentErrorLimit is the number of recent errors returned for every host
+const recentErrorLimit = 5
+
+// isAdmin checks if the user's email is one of utils.AdminEmails
+func isAdmin(user models.User) bool {
+	for _, email := range strings.Split(utils.AdminEmails, ",") {
+		email = strings.TrimSpace(email)
+		if email != "" && strings.EqualFold(email, user.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminCtx middleware only lets the admins through. It must be used after SessionCtx.
+func AdminCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		user, err := models.LayerInstance().User.GetByID(userID)
+		if err != nil {
+			render.Render(w, r, payloads.ErrUnauthorized(err))
+			return
+		}
+
+		if !isAdmin(user) {
+			render.Render(w, r, payloads.ErrForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetScraperHealth returns the success rates, last success, recent errors and circuit breaker state
+// of the scrapes of every host
+func GetScraperHealth(w http.ResponseWriter, r *http.Request) {
+	health, err := models.LayerInstance().ScrapeResult.GetHealth()
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	recentErrors, err := models.LayerInstance().ScrapeResult.GetRecentErrors(recentErrorLimit)
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	if err := render.RenderList(w, r, payloads.NewScraperHealthListResponse(health, recentErrors, services.Breaker().State)); err != nil {
+		render.Render(w, r, payloads.ErrRender(err))
+		return
+	}
+}
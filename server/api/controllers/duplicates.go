@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/api/payloads"
+	"github.com/UN0wen/pricewatch-vn/server/services"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// GetItemDuplicates returns the items of other stores whose image is near-identical to the image of an item of the user
+func GetItemDuplicates(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := trackedItemID(w, r, "itemID")
+	if !ok {
+		return
+	}
+
+	renderSuggestions(w, r, itemID)
+}
+
+// DecideItemDuplicate confirms or rejects that two items of the user are the same product.
+// The decision regroups the products of every user, so pairs with an item the user doesn't track are left to the admins.
+func DecideItemDuplicate(w http.ResponseWriter, r *http.Request) {
+	itemID, ok := trackedItemID(w, r, "itemID")
+	if !ok {
+		return
+	}
+
+	if _, ok = trackedItemID(w, r, "duplicateID"); !ok {
+		return
+	}
+
+	decide(w, r, itemID)
+}
+
+// GetDuplicates returns every pair of items of different stores with near-identical images
+func GetDuplicates(w http.ResponseWriter, r *http.Request) {
+	renderSuggestions(w, r, uuid.Nil)
+}
+
+// DecideDuplicate confirms or rejects that two items are the same product
+func DecideDuplicate(w http.ResponseWriter, r *http.Request) {
+	itemIDParam := chi.URLParam(r, "itemID")
+	itemID, err := uuid.Parse(itemIDParam)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	decide(w, r, itemID)
+}
+
+// trackedItemID reads the item id in the URL parameter param, which must be an item the user tracks.
+// ok is false if the error was already rendered.
+func trackedItemID(w http.ResponseWriter, r *http.Request, param string) (itemID uuid.UUID, ok bool) {
+	itemIDParam := chi.URLParam(r, param)
+	itemID, err := uuid.Parse(itemIDParam)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+
+	if _, err = models.LayerInstance().UserItem.GetByUserItem(userID, itemID); err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	return itemID, true
+}
+
+// renderSuggestions renders the duplicate suggestions of an item, or of every item for uuid.Nil
+func renderSuggestions(w http.ResponseWriter, r *http.Request, itemID uuid.UUID) {
+	suggestions, err := services.DuplicateSuggestions(itemID)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	if err := render.RenderList(w, r, payloads.NewDuplicateSuggestionListResponse(suggestions)); err != nil {
+		render.Render(w, r, payloads.ErrRender(err))
+		return
+	}
+}
+
+// decide saves the user's decision on itemID and the duplicateID parameter
+func decide(w http.ResponseWriter, r *http.Request, itemID uuid.UUID) {
+	data := &payloads.DuplicateDecisionRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, payloads.ErrInvalidRequest(err))
+		return
+	}
+
+	duplicateIDParam := chi.URLParam(r, "duplicateID")
+	duplicateID, err := uuid.Parse(duplicateIDParam)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	if duplicateID == itemID {
+		render.Render(w, r, payloads.ErrInvalidRequest(errors.New("An item is not a duplicate of itself")))
+		return
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+
+	err = services.DecideDuplicate(itemID, duplicateID, *data.Confirmed, userID)
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	render.Status(r, 200)
+}
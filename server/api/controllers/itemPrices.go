@@ -11,6 +11,7 @@ import (
 )
 
 // GetPrice returns the most current price for item with id.
+// The price of a variant is returned if the sku query parameter is set.
 func GetPrice(w http.ResponseWriter, r *http.Request) {
 	var itemPrice models.ItemPrice
 	var err error
@@ -22,7 +23,7 @@ func GetPrice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	itemPrice, err = models.LayerInstance().ItemPrice.GetPrice(itemID)
+	itemPrice, err = models.LayerInstance().ItemPrice.GetPrice(itemID, r.URL.Query().Get("sku"))
 
 	if err != nil {
 		render.Render(w, r, payloads.ErrInternalError(err))
@@ -36,6 +37,7 @@ func GetPrice(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetPrices returns all prices for item with id
+// The prices of a variant are returned if the sku query parameter is set.
 func GetPrices(w http.ResponseWriter, r *http.Request) {
 	var err error
 	var itemPrices []models.ItemPrice
@@ -47,7 +49,7 @@ func GetPrices(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	itemPrices, err = models.LayerInstance().ItemPrice.GetAllPrices(itemID)
+	itemPrices, err = models.LayerInstance().ItemPrice.GetAllPrices(itemID, r.URL.Query().Get("sku"))
 
 	if err != nil {
 		render.Render(w, r, payloads.ErrInternalError(err))
@@ -59,3 +61,78 @@ func GetPrices(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// GetOffers returns the current offer of every shop for item with id
+func GetOffers(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var itemOffers []models.ItemOffer
+	itemIDParam := chi.URLParam(r, "itemID")
+	itemID, err := uuid.Parse(itemIDParam)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	itemOffers, err = models.LayerInstance().ItemOffer.GetOffers(itemID)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	if err := render.RenderList(w, r, payloads.NewItemOfferListResponse(itemOffers)); err != nil {
+		render.Render(w, r, payloads.ErrRender(err))
+		return
+	}
+}
+
+// GetOfferHistory returns the price history of every shop for item with id
+func GetOfferHistory(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var itemOffers []models.ItemOffer
+	itemIDParam := chi.URLParam(r, "itemID")
+	itemID, err := uuid.Parse(itemIDParam)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	itemOffers, err = models.LayerInstance().ItemOffer.GetAllOffers(itemID)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	if err := render.RenderList(w, r, payloads.NewItemOfferListResponse(itemOffers)); err != nil {
+		render.Render(w, r, payloads.ErrRender(err))
+		return
+	}
+}
+
+// GetVariants returns every variant of item with id with its current price
+func GetVariants(w http.ResponseWriter, r *http.Request) {
+	var err error
+	var variants []models.ItemVariantWithPrice
+	itemIDParam := chi.URLParam(r, "itemID")
+	itemID, err := uuid.Parse(itemIDParam)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	variants, err = models.LayerInstance().ItemVariant.GetByItem(itemID)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	if err := render.RenderList(w, r, payloads.NewItemVariantListResponse(variants)); err != nil {
+		render.Render(w, r, payloads.ErrRender(err))
+		return
+	}
+}
@@ -2,12 +2,15 @@ package controllers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
-	"net/url"
+	"strings"
 
 	"github.com/UN0wen/pricewatch-vn/server/api/models"
 	"github.com/UN0wen/pricewatch-vn/server/api/payloads"
 	"github.com/UN0wen/pricewatch-vn/server/scraper"
+	"github.com/UN0wen/pricewatch-vn/server/services"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
 	"github.com/google/uuid"
@@ -104,7 +107,10 @@ func GetItems(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetItemsWithPrice returns all items with prices.
+// With ?currency=USD, every price is also converted to the currency, next to the original.
 func GetItemsWithPrice(w http.ResponseWriter, r *http.Request) {
+	currency := strings.ToUpper(r.URL.Query().Get("currency"))
+
 	items, err := models.LayerInstance().Item.GetAllWithPrice()
 
 	if err != nil {
@@ -112,46 +118,131 @@ func GetItemsWithPrice(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := render.RenderList(w, r, payloads.NewItemWithPriceListResponse(items)); err != nil {
+	if currency == "" {
+		if err := render.RenderList(w, r, payloads.NewItemWithPriceListResponse(items)); err != nil {
+			render.Render(w, r, payloads.ErrRender(err))
+		}
+		return
+	}
+
+	rates, err := services.LoadRates()
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	if _, ok := rates[currency]; !ok {
+		render.Render(w, r, payloads.ErrInvalidRequest(fmt.Errorf("Unknown currency %s", currency)))
+		return
+	}
+
+	list := []render.Renderer{}
+	for i := range items {
+		resp := payloads.NewItemWithPriceResponse(&items[i])
+		resp.Converted, err = convertPrice(&items[i], rates, currency)
+		if err != nil {
+			// The item is still listed with its original price
+			utils.Sugar.Infof("Could not convert the price of item %s: %s", items[i].Item.ID, err)
+		}
+		list = append(list, resp)
+	}
+
+	if err := render.RenderList(w, r, list); err != nil {
 		render.Render(w, r, payloads.ErrRender(err))
 		return
 	}
 }
 
+// convertPrice converts the prices of an item to currency
+func convertPrice(item *models.ItemWithPrice, rates services.ExchangeRates, currency string) (converted *payloads.ConvertedPrice, err error) {
+	from := item.Item.Currency
+	result := payloads.ConvertedPrice{Currency: currency}
+
+	result.Price, err = rates.Convert(item.Price, from, currency)
+	if err != nil {
+		return
+	}
+
+	result.OriginalPrice, err = rates.Convert(item.OriginalPrice, from, currency)
+	if err != nil {
+		return
+	}
+
+	result.RegularPrice, err = rates.Convert(item.RegularPrice, from, currency)
+	if err != nil {
+		return
+	}
+
+	if item.PromoPrice != nil {
+		var promoPrice int64
+		promoPrice, err = rates.Convert(*item.PromoPrice, from, currency)
+		if err != nil {
+			return
+		}
+		result.PromoPrice = &promoPrice
+	}
+
+	converted = &result
+	return
+}
+
 // CreateItem creates a new item and then return the item if it is successful
 // It expects an URL that it can use to parse into an item object
 func CreateItem(w http.ResponseWriter, r *http.Request) {
 	var itemPrice models.ItemPrice
+	var offers []models.ItemOffer
 	data := &payloads.ItemRequest{}
 	if err := render.Bind(r, data); err != nil {
 		render.Render(w, r, payloads.ErrInvalidRequest(err))
 		return
 	}
 	item := data.Item
+	userID := r.Context().Value("userID").(uuid.UUID)
 
-	// insert new item
-	returnedItem, err := models.LayerInstance().Item.Insert(*item)
+	canonical, err := scraper.Instance().Canonicalize(item.URL)
 	if err != nil {
-		render.Render(w, r, payloads.ErrInternalError(err))
+		render.Render(w, r, payloads.ErrInvalidRequest(err))
 		return
 	}
+	// the item is scraped and stored with the canonical link, without tracking parameters
+	item.URL = canonical.URL.String()
+	item.StoreKey = canonical.Key
 
-	path, err := url.Parse(returnedItem.URL)
+	// the same product may already be tracked from another link
+	existingItem, err := models.LayerInstance().Item.GetByStoreKey(canonical.Key)
 	if err != nil {
-		render.Render(w, r, payloads.ErrInvalidRequest(err))
+		render.Render(w, r, payloads.ErrInternalError(err))
 		return
-	}
-	// get item's price
-	if correspondingScraper, ok := scraper.Instance().Scrapers[path.Host]; ok {
-		itemPrice, err = correspondingScraper.ScrapePrice(returnedItem)
+	} else if existingItem.ID != uuid.Nil {
+		_, err = models.LayerInstance().UserItem.Insert(models.UserItem{UserID: userID, ItemID: existingItem.ID})
 		if err != nil {
 			render.Render(w, r, payloads.ErrInternalError(err))
 			return
 		}
-	} else {
-		render.Render(w, r, payloads.ErrNotImplemented)
+
+		if err := render.Render(w, r, payloads.NewItemResponse(&existingItem)); err != nil {
+			render.Render(w, r, payloads.ErrRender(err))
+		}
+		return
+	}
+
+	// get item's price before inserting it, so an item that can't be scraped is never tracked
+	correspondingScraper, _ := scraper.Instance().Get(canonical.Host)
+	ctx := scraper.WithDownloads(r.Context())
+	itemPrice, offers, err = scraper.ScrapeListing(ctx, correspondingScraper, *item)
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
 		return
 	}
+
+	// insert new item
+	returnedItem, err := models.LayerInstance().Item.Insert(*item)
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+	services.RecordScrape(correspondingScraper, returnedItem, itemPrice, nil)
+
 	itemPrice.ItemID = returnedItem.ID
 	// add itemPrice to itemPrice
 	_, err = models.LayerInstance().ItemPrice.Insert(itemPrice)
@@ -160,8 +251,35 @@ func CreateItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// add every shop's offer to itemOffers
+	for _, offer := range offers {
+		offer.ItemID = returnedItem.ID
+		_, err = models.LayerInstance().ItemOffer.Insert(offer)
+		if err != nil {
+			render.Render(w, r, payloads.ErrInternalError(err))
+			return
+		}
+	}
+
+	// add the price of every variant, so the user can pick one to watch, they are scraped again at the next update
+	if err := services.UpdateVariants(ctx, correspondingScraper, returnedItem); err != nil {
+		utils.Sugar.Infof("%s", err)
+	}
+
+	// add the running promotions, they are not required for the item to be tracked
+	if err := services.UpdatePromotions(ctx, correspondingScraper, returnedItem); err != nil {
+		utils.Sugar.Infof("%s", err)
+	}
+
+	// group the item with the same product in other stores, it can be grouped again later
+	if _, err := services.GroupItem(returnedItem); err != nil {
+		utils.Sugar.Infof("%s", err)
+	}
+
+	// hash the item's image to suggest its duplicates in other stores, the download doesn't hold up the response
+	services.HashInBackground(returnedItem)
+
 	// add item to userItems
-	userID := r.Context().Value("userID").(uuid.UUID)
 	_, err = models.LayerInstance().UserItem.Insert(models.UserItem{UserID: userID, ItemID: returnedItem.ID})
 	if err != nil {
 		render.Render(w, r, payloads.ErrInternalError(err))
@@ -185,25 +303,41 @@ func CreateItemFromURL(w http.ResponseWriter, r *http.Request) {
 	}
 	item := data.Item
 
-	path, err := url.Parse(item.URL)
+	canonical, err := scraper.Instance().Canonicalize(item.URL)
 	if err != nil {
 		render.Render(w, r, payloads.ErrInvalidRequest(err))
 		return
 	}
+	path := canonical.URL
 
 	// Get new Item
-	if correspondingScraper, ok := scraper.Instance().Scrapers[path.Host]; ok {
-		*item, err = correspondingScraper.ScrapeInfo(path)
-		if err != nil {
+	var strategy string
+	if correspondingScraper, generic := scraper.Instance().Get(path.Host); !generic {
+		// The scrape is cancelled if the client disconnects
+		*item, err = scraper.ScrapeInfo(r.Context(), correspondingScraper, path)
+		var robotsErr *scraper.RobotsError
+		if errors.As(err, &robotsErr) {
+			// The store doesn't let us scrape the page, so it can't be tracked
+			render.Render(w, r, payloads.ErrInvalidRequest(err))
+			return
+		} else if err != nil {
 			render.Render(w, r, payloads.ErrInternalError(err))
 			return
 		}
 	} else {
-		render.Render(w, r, payloads.ErrNotImplemented)
-		return
+		// Unknown stores are supported if their pages have structured price data
+		*item, strategy, err = scraper.Instance().Generic.Detect(r.Context(), path)
+		if err != nil {
+			render.Render(w, r, payloads.ErrNotImplemented)
+			return
+		}
 	}
+	item.URL = path.String()
+	item.StoreKey = canonical.Key
 
-	if err := render.Render(w, r, payloads.NewItemResponse(item)); err != nil {
+	resp := payloads.NewItemResponse(item)
+	resp.Strategy = strategy
+	if err := render.Render(w, r, resp); err != nil {
 		render.Render(w, r, payloads.ErrRender(err))
 		return
 	}
@@ -218,14 +352,17 @@ func ValidateURL(w http.ResponseWriter, r *http.Request) {
 	}
 	item := data.Item
 
-	path, err := url.Parse(item.URL)
+	canonical, err := scraper.Instance().Canonicalize(item.URL)
 	if err != nil {
 		render.Render(w, r, payloads.ErrInvalidRequest(err))
 		return
 	}
+	path := canonical.URL
 
-	// Check if corresponding scraper exists
-	if _, ok := scraper.Instance().Scrapers[path.Host]; ok {
+	// Check if corresponding scraper exists, or if the generic scraper can read the page
+	if _, generic := scraper.Instance().Get(path.Host); !generic {
+		render.Status(r, http.StatusOK)
+	} else if _, _, err = scraper.Instance().Generic.Detect(r.Context(), path); err == nil {
 		render.Status(r, http.StatusOK)
 	} else {
 		render.Render(w, r, payloads.ErrNotImplemented)
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/api/payloads"
+	"github.com/UN0wen/pricewatch-vn/server/services"
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// GetProduct returns the product with id, with its items in every store and the cheapest of them.
+func GetProduct(w http.ResponseWriter, r *http.Request) {
+	productIDParam := chi.URLParam(r, "productID")
+	productID, err := uuid.Parse(productIDParam)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	renderProduct(w, r, productID)
+}
+
+// GetItemProduct returns the product of the item with id, with its items in every store and the cheapest of them.
+func GetItemProduct(w http.ResponseWriter, r *http.Request) {
+	itemIDParam := chi.URLParam(r, "itemID")
+	itemID, err := uuid.Parse(itemIDParam)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	item, err := models.LayerInstance().Item.GetByID(itemID)
+
+	// items are grouped after they are created, so an item may not have a product yet
+	if err != nil || item.ProductID == nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	renderProduct(w, r, *item.ProductID)
+}
+
+// renderProduct renders the product with id with its items
+func renderProduct(w http.ResponseWriter, r *http.Request, productID uuid.UUID) {
+	product, err := models.LayerInstance().Product.GetByID(productID)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	items, err := models.LayerInstance().Product.GetItems(productID)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	rates, err := services.LoadRates()
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	resp := payloads.NewProductResponse(&product, items)
+	if cheapest, found := services.CheapestListing(items, rates); found {
+		resp.Cheapest = &payloads.CheapestOffer{
+			ItemID:   cheapest.Item.ID,
+			URL:      cheapest.URL,
+			Price:    cheapest.Price,
+			Currency: cheapest.Currency,
+		}
+		if cheapest.PromoPrice != nil && cheapest.Price == *cheapest.PromoPrice {
+			resp.Cheapest.PromoEndsAt = cheapest.PromoEndsAt
+		}
+	}
+
+	if err := render.Render(w, r, resp); err != nil {
+		render.Render(w, r, payloads.ErrRender(err))
+		return
+	}
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/api/payloads"
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// SearchStores searches every store that supports it for the keywords in q,
+// so the user can pick a product to track without finding its URL.
+func SearchStores(w http.ResponseWriter, r *http.Request) {
+	searchQuery := r.URL.Query().Get("q")
+	if searchQuery == "" {
+		render.Render(w, r, payloads.ErrInvalidRequest(errors.New("Query must exists")))
+		return
+	}
+
+	// The searches are cancelled if the client disconnects
+	results, err := scraper.Instance().Search(r.Context(), searchQuery)
+	if err != nil {
+		render.Render(w, r, payloads.ErrInternalError(err))
+		return
+	}
+
+	storeKeys := make([]string, 0, len(results))
+	for _, result := range results {
+		storeKeys = append(storeKeys, result.StoreKey)
+	}
+
+	// The results are still useful without knowing which of them are tracked
+	items, err := models.LayerInstance().Item.GetByStoreKeys(storeKeys)
+	if err != nil {
+		utils.Sugar.Errorf("Could not check which results of %s are tracked: %s", searchQuery, err)
+	}
+
+	tracked := make(map[string]uuid.UUID)
+	for _, item := range items {
+		tracked[item.StoreKey] = item.ID
+	}
+
+	if err := render.RenderList(w, r, payloads.NewSearchResultListResponse(results, tracked)); err != nil {
+		render.Render(w, r, payloads.ErrRender(err))
+		return
+	}
+}
@@ -31,10 +31,11 @@ func Subscribe(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(uuid.UUID)
 
 	sub := models.Subscription{
-		UserID:      userID,
-		ItemID:      itemID,
-		Email:       inSub.Email,
-		TargetPrice: inSub.TargetPrice,
+		UserID:       userID,
+		ItemID:       itemID,
+		Email:        inSub.Email,
+		TargetPrice:  inSub.TargetPrice,
+		OfficialOnly: inSub.OfficialOnly,
 	}
 
 	_, err = models.LayerInstance().Subscription.Insert(sub)
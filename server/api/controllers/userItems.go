@@ -68,3 +68,42 @@ func CreateUserItem(w http.ResponseWriter, r *http.Request) {
 
 	render.Status(r, http.StatusOK)
 }
+
+// UpdateUserItem changes the variant of an item the user watches.
+// An empty sku watches the item's own price again.
+func UpdateUserItem(w http.ResponseWriter, r *http.Request) {
+	data := &payloads.UserItemRequest{}
+	if err := render.Bind(r, data); err != nil {
+		render.Render(w, r, payloads.ErrInvalidRequest(err))
+		return
+	}
+
+	itemIDParam := chi.URLParam(r, "itemID")
+	itemID, err := uuid.Parse(itemIDParam)
+
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	// the variant must have a price to be watched
+	sku := data.UserItem.SKU
+	if sku != "" {
+		if _, err = models.LayerInstance().ItemPrice.GetPrice(itemID, sku); err != nil {
+			render.Render(w, r, payloads.ErrNotFound)
+			return
+		}
+	}
+
+	userID := r.Context().Value("userID").(uuid.UUID)
+	userItem, err := models.LayerInstance().UserItem.SetVariant(userID, itemID, sku)
+	if err != nil {
+		render.Render(w, r, payloads.ErrNotFound)
+		return
+	}
+
+	if err := render.Render(w, r, payloads.NewUserItemResponse(&userItem)); err != nil {
+		render.Render(w, r, payloads.ErrRender(err))
+		return
+	}
+}
@@ -12,10 +12,17 @@ import (
 type layer struct {
 	User         *UserTable
 	Item         *ItemTable
+	Product      *ProductTable
 	UserItem     *UserItemTable
 	ItemPrice    *ItemPriceTable
+	ItemOffer    *ItemOfferTable
+	ItemVariant  *ItemVariantTable
+	Duplicate    *ItemDuplicateTable
+	Promotion    *ItemPromotionTable
 	Session      *SessionTable
 	Subscription *SubscriptionTable
+	ScrapeResult *ScrapeResultTable
+	ExchangeRate *ExchangeRateTable
 }
 
 // Singleton reference to the model layer.
@@ -44,10 +51,17 @@ func LayerInstance() *layer {
 		instance = &layer{
 			User:         &UserTable{connection: &db},
 			Item:         &ItemTable{connection: &db},
+			Product:      &ProductTable{connection: &db},
 			UserItem:     &UserItemTable{connection: &db},
 			ItemPrice:    &ItemPriceTable{connection: &db},
+			ItemOffer:    &ItemOfferTable{connection: &db},
+			ItemVariant:  &ItemVariantTable{connection: &db},
+			Duplicate:    &ItemDuplicateTable{connection: &db},
+			Promotion:    &ItemPromotionTable{connection: &db},
 			Session:      &SessionTable{connection: &db},
 			Subscription: &SubscriptionTable{connection: &db},
+			ScrapeResult: &ScrapeResultTable{connection: &db},
+			ExchangeRate: &ExchangeRateTable{connection: &db},
 		}
 	})
 	return instance
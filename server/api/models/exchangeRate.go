@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/db"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/asaskevich/govalidator"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/pkg/errors"
+)
+
+// ExchangeRateTableName is the name of the table holding the exchange rate of every currency
+const (
+	ExchangeRateTableName = "exchange_rates"
+)
+
+// ExchangeRateTable represents the connection to the db instance
+type ExchangeRateTable struct {
+	connection *db.Db
+}
+
+// ExchangeRate represents a single row in the ExchangeRateTable.
+// Rate is the value of one unit of Currency in the base currency, VND.
+type ExchangeRate struct {
+	Currency  string    `valid:"required" json:"currency"`
+	Rate      float64   `valid:"required" json:"rate"`
+	UpdatedAt time.Time `valid:"-" json:"updated_at" db:"updated_at"`
+}
+
+// GetAll gets the exchange rate of every currency
+func (table *ExchangeRateTable) GetAll() (rates []ExchangeRate, err error) {
+	var query string
+
+	query = fmt.Sprintf(`SELECT * FROM %s ORDER BY currency;`, ExchangeRateTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &rates, query)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+	return
+}
+
+// Upsert adds the exchange rate of a currency into the table, or replaces its rate if it already exists
+func (table *ExchangeRateTable) Upsert(rate ExchangeRate) (returnedRate ExchangeRate, err error) {
+	var query string
+	var values []interface{}
+	_, err = govalidator.ValidateStruct(rate)
+	if err != nil {
+		err = errors.Wrap(err, "Missing fields in ExchangeRate")
+		return
+	}
+
+	values = append(values, rate.Currency, rate.Rate, time.Now().Format(time.RFC3339))
+	query = fmt.Sprintf(`INSERT INTO "%s" (currency, rate, updated_at) VALUES ($1, $2, $3) ON CONFLICT (currency) DO UPDATE SET rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at RETURNING *;`, ExchangeRateTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	returnedRate = ExchangeRate{}
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &returnedRate, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Insertion query failed to execute")
+	}
+
+	return
+}
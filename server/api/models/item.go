@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/UN0wen/pricewatch-vn/server/db"
 	"github.com/UN0wen/pricewatch-vn/server/utils"
@@ -28,18 +29,29 @@ type ItemTable struct {
 
 // Item represents a single row in the ItemTable
 type Item struct {
-	ID          uuid.UUID `valid:"-" json:"id"`
-	Name        string    `valid:"required" json:"name"`
-	Description string    `valid:"required" json:"description"`
-	ImageURL    string    `valid:"required" json:"image_url" db:"image_url"`
-	URL         string    `valid:"required" json:"url"`
-	Currency    string    `valid:"required" json:"currency"`
+	ID          uuid.UUID  `valid:"-" json:"id"`
+	Name        string     `valid:"required" json:"name"`
+	Description string     `valid:"required" json:"description"`
+	ImageURL    string     `valid:"required" json:"image_url" db:"image_url"`
+	URL         string     `valid:"required" json:"url"`
+	Currency    string     `valid:"required" json:"currency"`
+	GTIN        string     `valid:"-" json:"gtin"`                       // GTIN-13 or ISBN-13 of the product, if the store publishes one
+	Model       string     `valid:"-" json:"model"`                      // manufacturer's model or part number, if the store publishes one
+	StoreKey    string     `valid:"-" json:"store_key" db:"store_key"`   // canonical store and product id, unique per product
+	Stale       bool       `valid:"-" json:"stale"`                      // the price wasn't updated because scraping of its store is paused
+	ProductID   *uuid.UUID `valid:"-" json:"product_id" db:"product_id"` // the product the item is a listing of, nil until it is grouped
+	ImageHash   *int64     `valid:"-" json:"image_hash" db:"image_hash"` // perceptual hash of the image, nil until it is hashed
+	// number of times the image couldn't be hashed, the item isn't hashed again at startup after a few
+	ImageHashFailures int `valid:"-" json:"-" db:"image_hash_failures"`
 }
 
 // ItemWithPrice represent the join between Item and ItemPrices
 type ItemWithPrice struct {
 	*ItemPrice
 	*Item
+	RegularPrice int64      `json:"regular_price" db:"regular_price"` // price outside of the current promotion
+	PromoPrice   *int64     `json:"promo_price" db:"promo_price"`     // nil if the item isn't on promotion
+	PromoEndsAt  *time.Time `json:"promo_ends_at" db:"promo_ends_at"`
 }
 
 // ItemQuery represents all of the rows the item can be queried over
@@ -83,6 +95,46 @@ func (table *ItemTable) GetByID(id uuid.UUID) (item Item, err error) {
 	return
 }
 
+// GetByStoreKey finds an item by its canonical store key.
+// It returns an empty item if no item has this key.
+func (table *ItemTable) GetByStoreKey(storeKey string) (item Item, err error) {
+	var query string
+	var values []interface{}
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE store_key=$1;`, ItemTableName)
+
+	values = append(values, storeKey)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &item, query, values...)
+	if pgxscan.NotFound(err) {
+		err = nil
+	} else if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+	}
+
+	return
+}
+
+// GetByStoreKeys finds the items with any of the canonical store keys.
+// Keys that no item has are skipped.
+func (table *ItemTable) GetByStoreKeys(storeKeys []string) (items []Item, err error) {
+	var query string
+	var values []interface{}
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE store_key = ANY($1);`, ItemTableName)
+
+	values = append(values, storeKeys)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &items, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+	}
+
+	return
+}
+
 // GetAllWithPrice gets all items with price from the table
 func (table *ItemTable) GetAllWithPrice() (items []ItemWithPrice, err error) {
 	var query string
@@ -149,8 +201,13 @@ func (table *ItemTable) Insert(item Item) (returnedItem Item, err error) {
 		return
 	}
 
-	values = append(values, item.Name, item.Description, item.ImageURL, item.URL, item.Currency)
-	query = fmt.Sprintf(`INSERT INTO "%s" (name, description, image_url, url, currency) VALUES ($1, $2, $3, $4, $5) RETURNING *;`, ItemTableName)
+	if item.StoreKey == "" {
+		err = errors.New("Missing StoreKey in Item")
+		return
+	}
+
+	values = append(values, item.Name, item.Description, item.ImageURL, item.URL, item.Currency, item.GTIN, item.Model, item.StoreKey)
+	query = fmt.Sprintf(`INSERT INTO "%s" (name, description, image_url, url, currency, gtin, model, store_key) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;`, ItemTableName)
 
 	utils.Sugar.Infof("SQL Query: %s", query)
 	utils.Sugar.Infof("Values: %s", values)
@@ -164,6 +221,117 @@ func (table *ItemTable) Insert(item Item) (returnedItem Item, err error) {
 	return
 }
 
+// SetStale marks the price of an item as stale, or as up to date
+func (table *ItemTable) SetStale(id uuid.UUID, stale bool) (err error) {
+	var query string
+	var values []interface{}
+
+	values = append(values, id, stale)
+	query = fmt.Sprintf(`UPDATE "%s" SET stale=$2 WHERE id=$1;`, ItemTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	_, err = table.connection.Pool.Exec(context.Background(), query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Update query failed to execute")
+	}
+
+	return
+}
+
+// SetProduct links an item to the product it is a listing of
+func (table *ItemTable) SetProduct(id uuid.UUID, productID uuid.UUID) (err error) {
+	var query string
+	var values []interface{}
+
+	values = append(values, id, productID)
+	query = fmt.Sprintf(`UPDATE "%s" SET product_id=$2 WHERE id=$1;`, ItemTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	_, err = table.connection.Pool.Exec(context.Background(), query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Update query failed to execute")
+	}
+
+	return
+}
+
+// GetUngrouped gets the items that aren't linked to a product yet
+func (table *ItemTable) GetUngrouped() (items []Item, err error) {
+	var query string
+
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE product_id IS NULL;`, ItemTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &items, query)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+	return
+}
+
+// SetImageHash saves the perceptual hash of an item's image
+func (table *ItemTable) SetImageHash(id uuid.UUID, hash int64) (err error) {
+	var query string
+	var values []interface{}
+
+	values = append(values, id, hash)
+	query = fmt.Sprintf(`UPDATE "%s" SET image_hash=$2 WHERE id=$1;`, ItemTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	_, err = table.connection.Pool.Exec(context.Background(), query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Update query failed to execute")
+	}
+
+	return
+}
+
+// AddImageHashFailure counts a failed attempt at hashing the image of an item
+func (table *ItemTable) AddImageHashFailure(id uuid.UUID) (err error) {
+	var query string
+	var values []interface{}
+
+	values = append(values, id)
+	query = fmt.Sprintf(`UPDATE "%s" SET image_hash_failures=image_hash_failures+1 WHERE id=$1;`, ItemTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	_, err = table.connection.Pool.Exec(context.Background(), query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Update query failed to execute")
+	}
+
+	return
+}
+
+// GetUnhashed gets the items whose image isn't hashed yet and failed to be hashed less than maxFailures times
+func (table *ItemTable) GetUnhashed(maxFailures int) (items []Item, err error) {
+	var query string
+	var values []interface{}
+
+	values = append(values, maxFailures)
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE image_hash IS NULL AND image_url <> '' AND image_hash_failures < $1;`, ItemTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &items, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+	return
+}
+
 // Update will update the item row with an incoming item
 func (table *ItemTable) Update(id uuid.UUID, newItem Item) (updated Item, err error) {
 	data, err := table.connection.Update(id, ItemTableName, newItem)
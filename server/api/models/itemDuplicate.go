@@ -0,0 +1,135 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/db"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ItemDuplicateTableName is the name of the table holding the decisions on duplicate suggestions
+const (
+	ItemDuplicateTableName = "item_duplicates"
+)
+
+// ItemDuplicateTable represents the connection to the db instance
+type ItemDuplicateTable struct {
+	connection *db.Db
+}
+
+// ItemDuplicate represents a single row in the ItemDuplicateTable:
+// a user confirmed or rejected that two items are listings of the same product.
+// ItemID is always the lowest of the two ids.
+type ItemDuplicate struct {
+	ItemID      uuid.UUID  `json:"item_id" db:"item_id"`
+	DuplicateID uuid.UUID  `json:"duplicate_id" db:"duplicate_id"`
+	Confirmed   bool       `json:"confirmed"`
+	DecidedBy   *uuid.UUID `json:"decided_by" db:"decided_by"`
+	DecidedAt   time.Time  `json:"decided_at" db:"decided_at"`
+}
+
+// DuplicateSuggestion is a pair of items of different stores with near-identical images
+// that aren't grouped into the same product, and that no one decided on yet
+type DuplicateSuggestion struct {
+	ItemID            uuid.UUID `json:"item_id" db:"item_id"`
+	ItemName          string    `json:"item_name" db:"item_name"`
+	ItemURL           string    `json:"item_url" db:"item_url"`
+	ItemImageURL      string    `json:"item_image_url" db:"item_image_url"`
+	DuplicateID       uuid.UUID `json:"duplicate_id" db:"duplicate_id"`
+	DuplicateName     string    `json:"duplicate_name" db:"duplicate_name"`
+	DuplicateURL      string    `json:"duplicate_url" db:"duplicate_url"`
+	DuplicateImageURL string    `json:"duplicate_image_url" db:"duplicate_image_url"`
+	Distance          int       `json:"distance"` // number of bits the image hashes differ by, 0 for identical images
+}
+
+// OrderPair orders the ids of two items like the ItemDuplicateTable does
+func OrderPair(a uuid.UUID, b uuid.UUID) (uuid.UUID, uuid.UUID) {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		return b, a
+	}
+	return a, b
+}
+
+// suggestionQuery selects the suggestions with a distance of at most $1, the most similar first.
+// Every pair of hashed items is compared, filter is added to the conditions.
+func suggestionQuery(filter string) string {
+	query := fmt.Sprintf(`SELECT a.id AS item_id, a.name AS item_name, a.url AS item_url, a.image_url AS item_image_url, `+
+		`b.id AS duplicate_id, b.name AS duplicate_name, b.url AS duplicate_url, b.image_url AS duplicate_image_url, `+
+		`hash_distance(a.image_hash, b.image_hash) AS distance `+
+		`FROM %s a JOIN %s b ON a.id < b.id `+
+		`WHERE hash_distance(a.image_hash, b.image_hash) <= $1 `+
+		`AND split_part(a.store_key, '/', 1) <> split_part(b.store_key, '/', 1) `+
+		`AND (a.product_id IS NULL OR b.product_id IS NULL OR a.product_id <> b.product_id) `+
+		`AND NOT EXISTS (SELECT 1 FROM %s d WHERE d.item_id = a.id AND d.duplicate_id = b.id) `,
+		ItemTableName, ItemTableName, ItemDuplicateTableName)
+	return query + filter + ` ORDER BY distance, a.id, b.id;`
+}
+
+// GetSuggestions gets every duplicate suggestion whose images differ by at most maxDistance bits
+func (table *ItemDuplicateTable) GetSuggestions(maxDistance int) (suggestions []DuplicateSuggestion, err error) {
+	var query string
+	var values []interface{}
+	query = suggestionQuery("")
+
+	values = append(values, maxDistance)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &suggestions, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+	return
+}
+
+// GetSuggestionsFor gets the duplicate suggestions of an item whose images differ by at most maxDistance bits.
+// The item is ItemID or DuplicateID of the suggestions.
+func (table *ItemDuplicateTable) GetSuggestionsFor(itemID uuid.UUID, maxDistance int) (suggestions []DuplicateSuggestion, err error) {
+	var query string
+	var values []interface{}
+	query = suggestionQuery(`AND (a.id = $2 OR b.id = $2)`)
+
+	values = append(values, maxDistance, itemID)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &suggestions, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+	return
+}
+
+// Upsert saves the decision on a pair of items, replacing an earlier decision
+func (table *ItemDuplicateTable) Upsert(duplicate ItemDuplicate) (returnedDuplicate ItemDuplicate, err error) {
+	var query string
+	var values []interface{}
+
+	if duplicate.ItemID == uuid.Nil || duplicate.DuplicateID == uuid.Nil || duplicate.ItemID == duplicate.DuplicateID {
+		err = errors.New("Missing or identical item ids in ItemDuplicate")
+		return
+	}
+	duplicate.ItemID, duplicate.DuplicateID = OrderPair(duplicate.ItemID, duplicate.DuplicateID)
+
+	values = append(values, duplicate.ItemID, duplicate.DuplicateID, duplicate.Confirmed, duplicate.DecidedBy, time.Now().Format(time.RFC3339))
+	query = fmt.Sprintf(`INSERT INTO "%s" (item_id, duplicate_id, confirmed, decided_by, decided_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (item_id, duplicate_id) DO UPDATE SET confirmed = EXCLUDED.confirmed, decided_by = EXCLUDED.decided_by, decided_at = EXCLUDED.decided_at RETURNING *;`, ItemDuplicateTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	returnedDuplicate = ItemDuplicate{}
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &returnedDuplicate, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Insertion query failed to execute")
+	}
+
+	return
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/db"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/asaskevich/govalidator"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ItemOfferTableName is the name of the table holding the price of every shop's offer for an item
+const (
+	ItemOfferTableName = "item_offers"
+)
+
+// ItemOfferTable represents the connection to the db instance
+type ItemOfferTable struct {
+	connection *db.Db
+}
+
+// ItemOffer represents a single row in the ItemOfferTable.
+// Each seller of an item has its own price series.
+type ItemOffer struct {
+	ItemID    uuid.UUID `valid:"-" json:"item_id" db:"item_id"`
+	Seller    string    `valid:"required" json:"seller"`
+	URL       string    `valid:"-" json:"url"`
+	Time      time.Time `valid:"-" json:"time"`
+	Price     int64     `valid:"required" json:"price"`
+	Available bool      `valid:"-" json:"available"`
+	Official  bool      `valid:"-" json:"official"` // the seller is the brand's official store or the marketplace itself
+}
+
+// GetAllOffers gets the price history of every seller for a certain item
+func (table *ItemOfferTable) GetAllOffers(itemID uuid.UUID) (itemOffers []ItemOffer, err error) {
+	var query string
+	var values []interface{}
+
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE item_id=$1 ORDER BY seller, time DESC;`, ItemOfferTableName)
+
+	values = append(values, itemID)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &itemOffers, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+	return
+}
+
+// GetOffers gets the most current offer of every seller for a certain item, cheapest first
+func (table *ItemOfferTable) GetOffers(itemID uuid.UUID) (itemOffers []ItemOffer, err error) {
+	var query string
+	var values []interface{}
+
+	query = fmt.Sprintf(`SELECT * FROM (SELECT DISTINCT ON (seller) * FROM %s WHERE item_id=$1 ORDER BY seller, time DESC) AS latest ORDER BY price;`, ItemOfferTableName)
+
+	values = append(values, itemID)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &itemOffers, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+	return
+}
+
+// Insert adds a new offer into the table.
+func (table *ItemOfferTable) Insert(itemOffer ItemOffer) (returnedItemOffer ItemOffer, err error) {
+	var query string
+	var values []interface{}
+	_, err = govalidator.ValidateStruct(itemOffer)
+	if err != nil {
+		err = errors.Wrap(err, "Missing fields in ItemOffer")
+		return
+	}
+
+	if itemOffer.ItemID == uuid.Nil {
+		err = errors.New("Missing ItemID in ItemOffer")
+		return
+	}
+
+	values = append(values, itemOffer.ItemID, itemOffer.Seller, itemOffer.URL, time.Now().Format(time.RFC3339), itemOffer.Price, itemOffer.Available, itemOffer.Official)
+	query = fmt.Sprintf(`INSERT INTO "%s" (item_id, seller, url, time, price, available, official) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;`, ItemOfferTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	returnedItemOffer = ItemOffer{}
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &returnedItemOffer, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Insertion query failed to execute")
+	}
+
+	return
+}
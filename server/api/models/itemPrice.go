@@ -14,8 +14,10 @@ import (
 )
 
 // ItemPriceTableName is the name of the item's price table in the db
+// ItemPriceLatestView is the name of the view with the latest price of every item and variant
 const (
-	ItemPriceTableName = "item_prices"
+	ItemPriceTableName  = "item_prices"
+	ItemPriceLatestView = "latest_item_prices"
 )
 
 // ItemPriceTable represents the connection to the db instance
@@ -23,12 +25,21 @@ type ItemPriceTable struct {
 	connection *db.Db
 }
 
-// ItemPrice represents a single row in the ItemPriceTable
+// ItemPrice represents a single row in the ItemPriceTable.
+// Prices are in the minor unit of the item's currency, e.g. cents for USD and đồng for VND.
 type ItemPrice struct {
-	ItemID    uuid.UUID `valid:"-" json:"item_id" db:"item_id"`
-	Time      time.Time `valid:"-" json:"time"`
-	Price     int64     `valid:"required" json:"price"`
-	Available bool      `valid:"required" json:"available"`
+	ItemID        uuid.UUID `valid:"-" json:"item_id" db:"item_id"`
+	Time          time.Time `valid:"-" json:"time"`
+	Price         int64     `valid:"required" json:"price"`
+	Available     bool      `valid:"required" json:"available"`
+	SKU           string    `valid:"-" json:"sku"`                                // empty for the item's own price, the variant's SKU otherwise
+	Strategy      string    `valid:"-" json:"strategy"`                           // how the scraper read the price, e.g. "api" or "json-ld"
+	OriginalPrice int64     `valid:"-" json:"original_price" db:"original_price"` // strikethrough price before the discount, 0 if there is no discount
+	Discount      int64     `valid:"-" json:"discount"`                           // discount in percent of the original price
+	Seller        string    `valid:"-" json:"seller"`                             // empty if the store sells the item itself
+	ShippingFee   int64     `valid:"-" json:"shipping_fee" db:"shipping_fee"`     // 0 if shipping is free or unknown
+	Rating        float64   `valid:"-" json:"rating"`                             // average rating out of 5, 0 if the item is unrated
+	ReviewCount   int64     `valid:"-" json:"review_count" db:"review_count"`
 }
 
 // ItemPriceQuery represents all of the rows the item can be queried over
@@ -39,14 +50,14 @@ type ItemPriceQuery struct {
 	Available bool
 }
 
-// GetAllPrices gets all prices for a certain item
-func (table *ItemPriceTable) GetAllPrices(itemID uuid.UUID) (itemPrices []ItemPrice, err error) {
+// GetAllPrices gets all prices for a certain item, or for one of its variants if sku is set
+func (table *ItemPriceTable) GetAllPrices(itemID uuid.UUID, sku string) (itemPrices []ItemPrice, err error) {
 	var query string
 	var values []interface{}
 
-	query = fmt.Sprintf(`SELECT * FROM %s WHERE item_id=$1 ORDER BY time DESC;`, ItemPriceTableName)
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE item_id=$1 AND sku=$2 ORDER BY time DESC;`, ItemPriceTableName)
 
-	values = append(values, itemID)
+	values = append(values, itemID, sku)
 	utils.Sugar.Infof("SQL Query: %s", query)
 	utils.Sugar.Infof("Values: %s", values)
 
@@ -58,13 +69,13 @@ func (table *ItemPriceTable) GetAllPrices(itemID uuid.UUID) (itemPrices []ItemPr
 	return
 }
 
-// GetPrice gets the most current price of an item
-func (table *ItemPriceTable) GetPrice(itemID uuid.UUID) (itemPrice ItemPrice, err error) {
+// GetPrice gets the most current price of an item, or of one of its variants if sku is set
+func (table *ItemPriceTable) GetPrice(itemID uuid.UUID, sku string) (itemPrice ItemPrice, err error) {
 	var query string
 	var values []interface{}
-	query = fmt.Sprintf(`SELECT * FROM %s WHERE item_id=$1 ORDER BY time DESC LIMIT 1;`, ItemPriceTableName)
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE item_id=$1 AND sku=$2;`, ItemPriceLatestView)
 
-	values = append(values, itemID)
+	values = append(values, itemID, sku)
 	utils.Sugar.Infof("SQL Query: %s", query)
 	utils.Sugar.Infof("Values: %s", values)
 
@@ -92,8 +103,9 @@ func (table *ItemPriceTable) Insert(itemPrice ItemPrice) (returnedItemPrice Item
 		return
 	}
 
-	values = append(values, itemPrice.ItemID, time.Now().Format(time.RFC3339), itemPrice.Price, itemPrice.Available)
-	query = fmt.Sprintf(`INSERT INTO "%s" (item_id, time, price, available) VALUES ($1, $2, $3, $4) RETURNING *;`, ItemPriceTableName)
+	values = append(values, itemPrice.ItemID, time.Now().Format(time.RFC3339), itemPrice.Price, itemPrice.Available, itemPrice.SKU, itemPrice.Strategy,
+		itemPrice.OriginalPrice, itemPrice.Discount, itemPrice.Seller, itemPrice.ShippingFee, itemPrice.Rating, itemPrice.ReviewCount)
+	query = fmt.Sprintf(`INSERT INTO "%s" (item_id, time, price, available, sku, strategy, original_price, discount, seller, shipping_fee, rating, review_count) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING *;`, ItemPriceTableName)
 
 	utils.Sugar.Infof("SQL Query: %s", query)
 	utils.Sugar.Infof("Values: %s", values)
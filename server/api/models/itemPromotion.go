@@ -0,0 +1,92 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/db"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/asaskevich/govalidator"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ItemPromotionTableName is the name of the table holding the time-limited promotions of items, e.g. flash sales
+// ItemPromotionActiveView is the name of the view with the cheapest promotion that applies now for every item
+const (
+	ItemPromotionTableName  = "item_promotions"
+	ItemPromotionActiveView = "active_promotions"
+)
+
+// ItemPromotionTable represents the connection to the db instance
+type ItemPromotionTable struct {
+	connection *db.Db
+}
+
+// ItemPromotion represents a single row in the ItemPromotionTable.
+// The promotional price only applies between StartsAt and EndsAt.
+type ItemPromotion struct {
+	ItemID       uuid.UUID `valid:"-" json:"item_id" db:"item_id"`
+	Label        string    `valid:"-" json:"label"` // e.g. "Flash Sale"
+	Price        int64     `valid:"required" json:"price"`
+	RegularPrice int64     `valid:"-" json:"regular_price" db:"regular_price"` // price outside of the promotion, 0 if unknown
+	StartsAt     time.Time `valid:"required" json:"starts_at" db:"starts_at"`
+	EndsAt       time.Time `valid:"required" json:"ends_at" db:"ends_at"`
+}
+
+// GetActive gets the promotion of an item that applies now.
+// found is false if the item isn't on promotion.
+func (table *ItemPromotionTable) GetActive(itemID uuid.UUID) (promotion ItemPromotion, found bool, err error) {
+	var query string
+	var values []interface{}
+	var promotions []ItemPromotion
+
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE item_id=$1;`, ItemPromotionActiveView)
+
+	values = append(values, itemID)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &promotions, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+
+	if len(promotions) > 0 {
+		promotion, found = promotions[0], true
+	}
+	return
+}
+
+// Upsert adds a new promotion into the table, or updates its price and window if it was already scraped
+func (table *ItemPromotionTable) Upsert(promotion ItemPromotion) (returnedPromotion ItemPromotion, err error) {
+	var query string
+	var values []interface{}
+	_, err = govalidator.ValidateStruct(promotion)
+	if err != nil {
+		err = errors.Wrap(err, "Missing fields in ItemPromotion")
+		return
+	}
+
+	if promotion.ItemID == uuid.Nil {
+		err = errors.New("Missing ItemID in ItemPromotion")
+		return
+	}
+
+	values = append(values, promotion.ItemID, promotion.Label, promotion.Price, promotion.RegularPrice, promotion.StartsAt.Format(time.RFC3339), promotion.EndsAt.Format(time.RFC3339))
+	query = fmt.Sprintf(`INSERT INTO "%s" (item_id, label, price, regular_price, starts_at, ends_at) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (item_id, starts_at) DO UPDATE SET label = EXCLUDED.label, price = EXCLUDED.price, regular_price = EXCLUDED.regular_price, ends_at = EXCLUDED.ends_at RETURNING *;`, ItemPromotionTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	returnedPromotion = ItemPromotion{}
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &returnedPromotion, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Insertion query failed to execute")
+	}
+
+	return
+}
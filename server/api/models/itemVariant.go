@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/db"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/asaskevich/govalidator"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ItemVariantTableName is the name of the item's variant table in the db
+const (
+	ItemVariantTableName = "item_variants"
+)
+
+// ItemVariantTable represents the connection to the db instance
+type ItemVariantTable struct {
+	connection *db.Db
+}
+
+// ItemVariant represents a single row in the ItemVariantTable.
+// Each variant has its own prices in the ItemPriceTable, keyed by SKU.
+type ItemVariant struct {
+	ItemID uuid.UUID `valid:"-" json:"item_id" db:"item_id"`
+	SKU    string    `valid:"required" json:"sku"`
+	Name   string    `valid:"required" json:"name"`
+}
+
+// ItemVariantWithPrice represent the join between ItemVariant and its latest ItemPrice
+type ItemVariantWithPrice struct {
+	ItemVariant
+	Time      time.Time `json:"time"`
+	Price     int64     `json:"price"`
+	Available bool      `json:"available"`
+}
+
+// GetByItem gets all variants of an item with their latest price, cheapest first
+func (table *ItemVariantTable) GetByItem(itemID uuid.UUID) (variants []ItemVariantWithPrice, err error) {
+	var query string
+	var values []interface{}
+
+	query = fmt.Sprintf(`SELECT v.*, p.time, p.price, p.available FROM %s v INNER JOIN %s p ON p.item_id = v.item_id AND p.sku = v.sku WHERE v.item_id=$1 ORDER BY p.price;`, ItemVariantTableName, ItemPriceLatestView)
+
+	values = append(values, itemID)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &variants, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+	return
+}
+
+// Upsert adds a new variant into the table, or renames it if it already exists
+func (table *ItemVariantTable) Upsert(variant ItemVariant) (returnedVariant ItemVariant, err error) {
+	var query string
+	var values []interface{}
+	_, err = govalidator.ValidateStruct(variant)
+	if err != nil {
+		err = errors.Wrap(err, "Missing fields in ItemVariant")
+		return
+	}
+
+	if variant.ItemID == uuid.Nil {
+		err = errors.New("Missing ItemID in ItemVariant")
+		return
+	}
+
+	values = append(values, variant.ItemID, variant.SKU, variant.Name)
+	query = fmt.Sprintf(`INSERT INTO "%s" (item_id, sku, name) VALUES ($1, $2, $3) ON CONFLICT (item_id, sku) DO UPDATE SET name = EXCLUDED.name RETURNING *;`, ItemVariantTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	returnedVariant = ItemVariant{}
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &returnedVariant, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Insertion query failed to execute")
+	}
+
+	return
+}
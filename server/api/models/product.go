@@ -0,0 +1,217 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/db"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/asaskevich/govalidator"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ProductTableName is the name of the table holding the products that group the items of different stores
+const (
+	ProductTableName = "products"
+)
+
+// ProductTable represents the connection to the db instance
+type ProductTable struct {
+	connection *db.Db
+}
+
+// Product represents a single row in the ProductTable.
+// Every item is a listing of one product, e.g. the same phone sold by Tiki, Lazada and TGDD.
+type Product struct {
+	ID      uuid.UUID `valid:"-" json:"id"`
+	Name    string    `valid:"required" json:"name"`
+	GTIN    string    `valid:"-" json:"gtin"`  // GTIN-13 or ISBN-13, empty if none of the items has one
+	Model   string    `valid:"-" json:"model"` // model or part number, empty if none of the items has one
+	Created time.Time `valid:"-" json:"created"`
+}
+
+// ProductMatch is a product whose name is similar to an item's name
+type ProductMatch struct {
+	Product
+	Similarity float64 `json:"similarity"` // trigram similarity of the unaccented names, between 0 and 1
+}
+
+// GetByID finds a product by id
+func (table *ProductTable) GetByID(id uuid.UUID) (product Product, err error) {
+	var query string
+	var values []interface{}
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE id=$1;`, ProductTableName)
+
+	values = append(values, id)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &product, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+	}
+
+	return
+}
+
+// GetByIdentifiers finds a product by GTIN, or by model number if no product has the GTIN.
+// Model numbers are compared case insensitively, empty identifiers are ignored.
+// It returns an empty product if none matches.
+func (table *ProductTable) GetByIdentifiers(gtin string, model string) (product Product, err error) {
+	var query string
+	var values []interface{}
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE ($1 <> '' AND gtin=$1) OR ($2 <> '' AND upper(model)=upper($2)) `, ProductTableName)
+	query += `ORDER BY ($1 <> '' AND gtin=$1) DESC, created LIMIT 1;`
+
+	values = append(values, gtin, model)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &product, query, values...)
+	if pgxscan.NotFound(err) {
+		err = nil
+	} else if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+	}
+
+	return
+}
+
+// GetSimilar finds the product whose accent insensitive name is the most similar to name,
+// with a similarity of at least minSimilarity.
+// Products with another GTIN or model number than the given ones are never similar.
+// found is false if no product is similar enough.
+func (table *ProductTable) GetSimilar(name string, gtin string, model string, minSimilarity float64) (match ProductMatch, found bool, err error) {
+	var query string
+	var values []interface{}
+	var matches []ProductMatch
+
+	query = fmt.Sprintf(`SELECT *, similarity(f_lower_unaccent (name), f_lower_unaccent ($1)) AS similarity FROM %s `, ProductTableName)
+	query += `WHERE f_lower_unaccent (name) % f_lower_unaccent ($1) AND similarity(f_lower_unaccent (name), f_lower_unaccent ($1)) >= $4 `
+	query += `AND (gtin = '' OR $2 = '' OR gtin = $2) AND (model = '' OR $3 = '' OR upper(model) = upper($3)) `
+	query += `ORDER BY similarity DESC LIMIT 1;`
+
+	values = append(values, name, gtin, model, minSimilarity)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &matches, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+
+	if len(matches) > 0 {
+		match, found = matches[0], true
+	}
+	return
+}
+
+// GetItems gets the items of a product with their latest price, cheapest first
+func (table *ProductTable) GetItems(id uuid.UUID) (items []ItemWithPrice, err error) {
+	var query string
+	var values []interface{}
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE product_id=$1 ORDER BY price;`, ItemLatestView)
+
+	values = append(values, id)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &items, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+
+	return
+}
+
+// Insert adds a new product into the table.
+func (table *ProductTable) Insert(product Product) (returnedProduct Product, err error) {
+	var query string
+	var values []interface{}
+	_, err = govalidator.ValidateStruct(product)
+	if err != nil {
+		err = errors.Wrap(err, "Missing fields in Product")
+		return
+	}
+
+	values = append(values, product.Name, product.GTIN, product.Model)
+	query = fmt.Sprintf(`INSERT INTO "%s" (name, gtin, model) VALUES ($1, $2, $3) RETURNING *;`, ProductTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	returnedProduct = Product{}
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &returnedProduct, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Insertion query failed to execute")
+	}
+
+	return
+}
+
+// AddIdentifiers sets the GTIN and model number of a product that doesn't have them yet,
+// when one of its items publishes them. Identifiers the product already has are kept.
+func (table *ProductTable) AddIdentifiers(id uuid.UUID, gtin string, model string) (err error) {
+	var query string
+	var values []interface{}
+
+	values = append(values, id, gtin, model)
+	query = fmt.Sprintf(`UPDATE "%s" SET gtin = CASE WHEN gtin = '' THEN $2 ELSE gtin END, model = CASE WHEN model = '' THEN $3 ELSE model END WHERE id=$1;`, ProductTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	_, err = table.connection.Pool.Exec(context.Background(), query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Update query failed to execute")
+	}
+
+	return
+}
+
+// Merge moves the items of a product into another product and deletes it
+func (table *ProductTable) Merge(from uuid.UUID, into uuid.UUID) (err error) {
+	tx, err := table.connection.Pool.Begin(context.Background())
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot start the transaction")
+		return
+	}
+	defer tx.Rollback(context.Background())
+
+	var query string
+	var values []interface{}
+
+	values = append(values, from, into)
+	query = fmt.Sprintf(`UPDATE "%s" SET product_id=$2 WHERE product_id=$1;`, ItemTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	_, err = tx.Exec(context.Background(), query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Update query failed to execute")
+		return
+	}
+
+	query = fmt.Sprintf(`DELETE FROM "%s" WHERE id=$1;`, ProductTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values[:1])
+
+	_, err = tx.Exec(context.Background(), query, from)
+	if err != nil {
+		err = errors.Wrapf(err, "Delete query failed to execute")
+		return
+	}
+
+	err = tx.Commit(context.Background())
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot commit the transaction")
+	}
+	return
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/db"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/asaskevich/govalidator"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ScrapeResultTableName is the name of the table holding the outcome of every scrape
+const (
+	ScrapeResultTableName = "scrape_results"
+)
+
+// Outcomes of a scrape
+const (
+	OutcomeSuccess    = "success"
+	OutcomeNetwork    = "network"    // the store could not be reached or returned a server error
+	OutcomeParse      = "parse"      // the page was downloaded but the price could not be read
+	OutcomeBlocked    = "blocked"    // the store refused the request, e.g. with 403 or 429
+	OutcomeDisallowed = "disallowed" // the store's robots.txt disallows the page, so it wasn't requested
+)
+
+// ScrapeResultTable represents the connection to the db instance
+type ScrapeResultTable struct {
+	connection *db.Db
+}
+
+// ScrapeResult represents a single row in the ScrapeResultTable
+type ScrapeResult struct {
+	Host     string    `valid:"required" json:"host"`
+	Time     time.Time `valid:"-" json:"time"`
+	ItemID   uuid.UUID `valid:"-" json:"item_id" db:"item_id"`
+	Outcome  string    `valid:"required" json:"outcome"`
+	Error    string    `valid:"-" json:"error"`
+	Strategy string    `valid:"-" json:"strategy"` // strategy that read the price, for successful scrapes
+}
+
+// ScrapeHealth is the rolling summary of the scrapes of a host
+type ScrapeHealth struct {
+	Host          string     `json:"host"`
+	HourTotal     int64      `json:"hour_total" db:"hour_total"`
+	HourSuccesses int64      `json:"hour_successes" db:"hour_successes"`
+	DayTotal      int64      `json:"day_total" db:"day_total"`
+	DaySuccesses  int64      `json:"day_successes" db:"day_successes"`
+	NetworkErrors int64      `json:"network_errors" db:"network_errors"` // in the last day
+	ParseErrors   int64      `json:"parse_errors" db:"parse_errors"`     // in the last day
+	Blocked       int64      `json:"blocked"`                            // in the last day
+	Disallowed    int64      `json:"disallowed"`                         // in the last day
+	LastSuccess   *time.Time `json:"last_success" db:"last_success"`     // nil if the host was never scraped successfully
+}
+
+// GetHealth gets the summary of the scrapes of every host
+func (table *ScrapeResultTable) GetHealth() (health []ScrapeHealth, err error) {
+	var query string
+
+	query = fmt.Sprintf(`SELECT host,
+		count(*) FILTER (WHERE time > now() - interval '1 hour') AS hour_total,
+		count(*) FILTER (WHERE time > now() - interval '1 hour' AND outcome = '%[2]s') AS hour_successes,
+		count(*) FILTER (WHERE time > now() - interval '1 day') AS day_total,
+		count(*) FILTER (WHERE time > now() - interval '1 day' AND outcome = '%[2]s') AS day_successes,
+		count(*) FILTER (WHERE time > now() - interval '1 day' AND outcome = '%[3]s') AS network_errors,
+		count(*) FILTER (WHERE time > now() - interval '1 day' AND outcome = '%[4]s') AS parse_errors,
+		count(*) FILTER (WHERE time > now() - interval '1 day' AND outcome = '%[5]s') AS blocked,
+		count(*) FILTER (WHERE time > now() - interval '1 day' AND outcome = '%[6]s') AS disallowed,
+		max(time) FILTER (WHERE outcome = '%[2]s') AS last_success
+		FROM %[1]s GROUP BY host ORDER BY host;`,
+		ScrapeResultTableName, OutcomeSuccess, OutcomeNetwork, OutcomeParse, OutcomeBlocked, OutcomeDisallowed)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &health, query)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+	return
+}
+
+// GetRecentErrors gets the latest failed scrapes of every host, at most limit per host
+func (table *ScrapeResultTable) GetRecentErrors(limit int) (results []ScrapeResult, err error) {
+	var query string
+	var values []interface{}
+
+	query = fmt.Sprintf(`SELECT host, time, item_id, outcome, error, strategy FROM (SELECT *, row_number() OVER (PARTITION BY host ORDER BY time DESC) AS rn FROM %s WHERE outcome <> $1) AS t WHERE t.rn <= $2 ORDER BY host, time DESC;`, ScrapeResultTableName)
+
+	values = append(values, OutcomeSuccess, limit)
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	err = pgxscan.Select(context.Background(), table.connection.Pool, &results, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Get query failed to execute")
+		return
+	}
+	return
+}
+
+// Insert adds a new scrape result into the table.
+func (table *ScrapeResultTable) Insert(result ScrapeResult) (returnedResult ScrapeResult, err error) {
+	var query string
+	var values []interface{}
+	_, err = govalidator.ValidateStruct(result)
+	if err != nil {
+		err = errors.Wrap(err, "Missing fields in ScrapeResult")
+		return
+	}
+
+	if result.ItemID == uuid.Nil {
+		err = errors.New("Missing ItemID in ScrapeResult")
+		return
+	}
+
+	values = append(values, result.Host, time.Now().Format(time.RFC3339), result.ItemID, result.Outcome, result.Error, result.Strategy)
+	query = fmt.Sprintf(`INSERT INTO "%s" (host, time, item_id, outcome, error, strategy) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;`, ScrapeResultTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	returnedResult = ScrapeResult{}
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &returnedResult, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Insertion query failed to execute")
+	}
+
+	return
+}
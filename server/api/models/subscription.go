@@ -28,10 +28,11 @@ type SubscriptionTable struct {
 
 // Subscription represents a single row in the UserItemTable
 type Subscription struct {
-	UserID      uuid.UUID `valid:"required" json:"user_id" db:"user_id"`
-	ItemID      uuid.UUID `valid:"required" json:"item_id" db:"json_id"`
-	Email       string    `valid:"required" json:"email"`
-	TargetPrice int64     `valid:"required" json:"target_price" db:"target_price"`
+	UserID       uuid.UUID `valid:"required" json:"user_id" db:"user_id"`
+	ItemID       uuid.UUID `valid:"required" json:"item_id" db:"item_id"`
+	Email        string    `valid:"required" json:"email"`
+	TargetPrice  int64     `valid:"required" json:"target_price" db:"target_price"`
+	OfficialOnly bool      `valid:"-" json:"official_only" db:"official_only"` // only alert on offers of official sellers
 }
 
 // SubscriptionQuery represents all of the rows the item can be queried over
@@ -64,7 +65,7 @@ func (table *SubscriptionTable) GetByItem(itemID uuid.UUID) (subscriptions []Sub
 	var query string
 	var values []interface{}
 
-	query = fmt.Sprintf(`SELECT * FROM %s WHERE user_id=$1;`, SubscriptionTableName)
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE item_id=$1;`, SubscriptionTableName)
 
 	values = append(values, itemID)
 	utils.Sugar.Infof("SQL Query: %s", query)
@@ -93,8 +94,8 @@ func (table *SubscriptionTable) Insert(subscription Subscription) (returnedSubsc
 		return
 	}
 
-	values = append(values, subscription.UserID, subscription.ItemID, subscription.Email, subscription.TargetPrice)
-	query = fmt.Sprintf(`INSERT INTO "%s" (user_id, item_id, email, target_price) VALUES ($1, $2, $3, $4) RETURNING *;`, UserTableName)
+	values = append(values, subscription.UserID, subscription.ItemID, subscription.Email, subscription.TargetPrice, subscription.OfficialOnly)
+	query = fmt.Sprintf(`INSERT INTO "%s" (user_id, item_id, email, target_price, official_only) VALUES ($1, $2, $3, $4, $5) RETURNING *;`, SubscriptionTableName)
 
 	utils.Sugar.Infof("SQL Query: %s", query)
 	utils.Sugar.Infof("Values: %s", values)
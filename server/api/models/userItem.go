@@ -26,6 +26,7 @@ type UserItemTable struct {
 type UserItem struct {
 	UserID uuid.UUID `valid:"-" json:"user_id" db:"user_id"`
 	ItemID uuid.UUID `valid:"-" json:"item_id" db:"item_id"`
+	SKU    string    `valid:"-" json:"sku"` // the variant the user watches, empty for the whole item
 }
 
 // UserItemQuery represents all of the rows the item can be queried over
@@ -34,11 +35,12 @@ type UserItemQuery struct {
 	ItemID uuid.UUID
 }
 
-// GetByUser gets all items followed by user with userID
+// GetByUser gets all items followed by user with userID,
+// with the latest price of the variant the user watches
 func (table *UserItemTable) GetByUser(userID uuid.UUID) (items []ItemWithPrice, err error) {
 	var query string
 	var values []interface{}
-	query = fmt.Sprintf(`SELECT i.* FROM %s inner join %s i on user_items.item_id = i.id WHERE user_id=$1;`, UserItemTableName, ItemLatestView)
+	query = fmt.Sprintf(`SELECT i.*, p.time, p.price, p.available, p.sku, p.original_price, p.discount, COALESCE(NULLIF(promo.regular_price, 0), p.price) AS regular_price, promo.price AS promo_price, promo.ends_at AS promo_ends_at FROM %s ui INNER JOIN %s i ON ui.item_id = i.id INNER JOIN %s p ON p.item_id = ui.item_id AND p.sku = ui.sku LEFT JOIN %s promo ON promo.item_id = ui.item_id AND ui.sku = '' WHERE ui.user_id=$1;`, UserItemTableName, ItemTableName, ItemPriceLatestView, ItemPromotionActiveView)
 
 	values = append(values, userID)
 	utils.Sugar.Infof("SQL Query: %s", query)
@@ -56,7 +58,7 @@ func (table *UserItemTable) GetByUser(userID uuid.UUID) (items []ItemWithPrice,
 func (table *UserItemTable) GetByUserItem(userID uuid.UUID, itemID uuid.UUID) (returnedUserItem UserItem, err error) {
 	var query string
 	var values []interface{}
-	query = fmt.Sprintf(`SELECT * FROM %s WHERE user_id=$1,item_id=$2;`, UserItemTableName)
+	query = fmt.Sprintf(`SELECT * FROM %s WHERE user_id=$1 AND item_id=$2;`, UserItemTableName)
 
 	values = append(values, userID, itemID)
 	utils.Sugar.Infof("SQL Query: %s", query)
@@ -72,6 +74,7 @@ func (table *UserItemTable) GetByUserItem(userID uuid.UUID, itemID uuid.UUID) (r
 }
 
 // Insert adds a new item into the table.
+// If the user already tracks the item, the existing row is kept and userItem is returned as is.
 func (table *UserItemTable) Insert(userItem UserItem) (returnedUserItem UserItem, err error) {
 	var query string
 	var values []interface{}
@@ -82,20 +85,43 @@ func (table *UserItemTable) Insert(userItem UserItem) (returnedUserItem UserItem
 	}
 
 	values = append(values, userItem.UserID, userItem.ItemID)
-	query = fmt.Sprintf(`INSERT INTO "%s" (user_id, item_id) VALUES ($1, $2) RETURNING *;`, UserItemTableName)
+	query = fmt.Sprintf(`INSERT INTO "%s" (user_id, item_id) VALUES ($1, $2) ON CONFLICT (user_id, item_id) DO NOTHING RETURNING *;`, UserItemTableName)
 
 	utils.Sugar.Infof("SQL Query: %s", query)
 	utils.Sugar.Infof("Values: %s", values)
 
 	returnedUserItem = UserItem{}
 	err = pgxscan.Get(context.Background(), table.connection.Pool, &returnedUserItem, query, values...)
-	if err != nil {
+	if pgxscan.NotFound(err) {
+		// No row is returned when the user already tracks the item
+		returnedUserItem, err = userItem, nil
+	} else if err != nil {
 		err = errors.Wrapf(err, "Insertion query failed to execute")
 	}
 
 	return
 }
 
+// SetVariant changes the variant of an item that the user watches
+func (table *UserItemTable) SetVariant(userID uuid.UUID, itemID uuid.UUID, sku string) (returnedUserItem UserItem, err error) {
+	var query string
+	var values []interface{}
+
+	values = append(values, userID, itemID, sku)
+	query = fmt.Sprintf(`UPDATE "%s" SET sku=$3 WHERE user_id=$1 AND item_id=$2 RETURNING *;`, UserItemTableName)
+
+	utils.Sugar.Infof("SQL Query: %s", query)
+	utils.Sugar.Infof("Values: %s", values)
+
+	returnedUserItem = UserItem{}
+	err = pgxscan.Get(context.Background(), table.connection.Pool, &returnedUserItem, query, values...)
+	if err != nil {
+		err = errors.Wrapf(err, "Update query failed to execute")
+	}
+
+	return
+}
+
 // Update will update the item row with an incoming item
 func (table *UserItemTable) Update(id uuid.UUID, newUserItem UserItem) (updated UserItem, err error) {
 	data, err := table.connection.Update(id, UserItemTableName, newUserItem)
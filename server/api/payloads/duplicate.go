@@ -0,0 +1,50 @@
+package payloads
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/go-chi/render"
+)
+
+// DuplicateDecisionRequest is the request payload to confirm or reject a duplicate suggestion
+type DuplicateDecisionRequest struct {
+	Confirmed *bool `json:"confirmed"`
+}
+
+// Bind is the postprocessing for the DuplicateDecisionRequest after the request is unmarshalled
+func (a *DuplicateDecisionRequest) Bind(r *http.Request) error {
+	if a.Confirmed == nil {
+		return errors.New("missing required confirmed field")
+	}
+	return nil
+}
+
+// DuplicateSuggestionResponse is the response payload for the DuplicateSuggestion data model.
+type DuplicateSuggestionResponse struct {
+	*models.DuplicateSuggestion
+}
+
+// NewDuplicateSuggestionResponse generate a Response for a DuplicateSuggestion object
+func NewDuplicateSuggestionResponse(suggestion *models.DuplicateSuggestion) *DuplicateSuggestionResponse {
+	resp := &DuplicateSuggestionResponse{DuplicateSuggestion: suggestion}
+
+	return resp
+}
+
+// NewDuplicateSuggestionListResponse generates a list of renders for DuplicateSuggestions
+func NewDuplicateSuggestionListResponse(suggestions []models.DuplicateSuggestion) []render.Renderer {
+	list := []render.Renderer{}
+	for i := range suggestions {
+		list = append(list, NewDuplicateSuggestionResponse(&suggestions[i]))
+	}
+
+	return list
+}
+
+// Render is preprocessing before the response is marshalled
+func (rd *DuplicateSuggestionResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
@@ -60,6 +60,9 @@ func ErrUnauthorized(err error) render.Renderer {
 	}
 }
 
+// ErrForbidden is a response payload with status code 403.
+var ErrForbidden = &ErrResponse{HTTPStatusCode: 403, StatusText: "Access forbidden."}
+
 // ErrInternalError is a response payload with status code 500.
 func ErrInternalError(err error) render.Renderer {
 	return &ErrResponse{
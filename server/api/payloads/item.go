@@ -23,7 +23,8 @@ func (a *ItemRequest) Bind(r *http.Request) error {
 
 // ItemResponse is the response payload for the Item data model.
 type ItemResponse struct {
-	Item *models.Item `json:"item"`
+	Item     *models.Item `json:"item"`
+	Strategy string       `json:"strategy,omitempty"` // how the generic scraper reads the item's price
 }
 
 // NewItemResponse generate a Response for Item object
@@ -52,6 +53,17 @@ func (rd *ItemResponse) Render(w http.ResponseWriter, r *http.Request) error {
 // ItemWithPriceResponse is the response payload for the ItemWithPrice data model.
 type ItemWithPriceResponse struct {
 	ItemWithPrice *models.ItemWithPrice `json:"item_with_price"`
+	Converted     *ConvertedPrice       `json:"converted,omitempty"` // only if the request asked for a currency
+}
+
+// ConvertedPrice is the price of an item converted to the currency the user asked for,
+// in the minor unit of the currency like every other price
+type ConvertedPrice struct {
+	Currency      string `json:"currency"`
+	Price         int64  `json:"price"`
+	OriginalPrice int64  `json:"original_price"`
+	RegularPrice  int64  `json:"regular_price"`
+	PromoPrice    *int64 `json:"promo_price"`
 }
 
 // NewItemWithPriceResponse generate a Response for ItemWithPrice object
@@ -34,3 +34,59 @@ func (rd *ItemPriceResponse) Render(w http.ResponseWriter, r *http.Request) erro
 	// Pre-processing before a response is marshalled and sent across the wire
 	return nil
 }
+
+// ItemOfferResponse is the response payload for the ItemOffer data model.
+type ItemOfferResponse struct {
+	ItemOffer *models.ItemOffer `json:"offer"`
+}
+
+// NewItemOfferResponse generate a Response for ItemOffer object
+func NewItemOfferResponse(itemOffer *models.ItemOffer) *ItemOfferResponse {
+	resp := &ItemOfferResponse{ItemOffer: itemOffer}
+
+	return resp
+}
+
+// NewItemOfferListResponse generates a list of renders for ItemOffers
+func NewItemOfferListResponse(itemOffers []models.ItemOffer) []render.Renderer {
+	list := []render.Renderer{}
+	for i := range itemOffers {
+		list = append(list, NewItemOfferResponse(&itemOffers[i]))
+	}
+
+	return list
+}
+
+// Render is preprocessing before the response is marshalled
+func (rd *ItemOfferResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
+
+// ItemVariantResponse is the response payload for the ItemVariantWithPrice data model.
+type ItemVariantResponse struct {
+	ItemVariant *models.ItemVariantWithPrice `json:"variant"`
+}
+
+// NewItemVariantResponse generate a Response for ItemVariantWithPrice object
+func NewItemVariantResponse(variant *models.ItemVariantWithPrice) *ItemVariantResponse {
+	resp := &ItemVariantResponse{ItemVariant: variant}
+
+	return resp
+}
+
+// NewItemVariantListResponse generates a list of renders for ItemVariantWithPrices
+func NewItemVariantListResponse(variants []models.ItemVariantWithPrice) []render.Renderer {
+	list := []render.Renderer{}
+	for i := range variants {
+		list = append(list, NewItemVariantResponse(&variants[i]))
+	}
+
+	return list
+}
+
+// Render is preprocessing before the response is marshalled
+func (rd *ItemVariantResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
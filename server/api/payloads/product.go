@@ -0,0 +1,41 @@
+package payloads
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/google/uuid"
+)
+
+// ProductResponse is the response payload for the Product data model, with the items of the product
+type ProductResponse struct {
+	Product  *models.Product        `json:"product"`
+	Items    []models.ItemWithPrice `json:"items"`    // cheapest first
+	Cheapest *CheapestOffer         `json:"cheapest"` // nil if no item is available
+}
+
+// CheapestOffer is the item of a product that can be bought for the least now, across all stores
+type CheapestOffer struct {
+	ItemID      uuid.UUID  `json:"item_id"`
+	URL         string     `json:"url"`
+	Price       int64      `json:"price"` // the promotion's price if the item is on promotion
+	Currency    string     `json:"currency"`
+	PromoEndsAt *time.Time `json:"promo_ends_at"` // nil if Price isn't a promotion
+}
+
+// NewProductResponse generate a Response for a Product object
+func NewProductResponse(product *models.Product, items []models.ItemWithPrice) *ProductResponse {
+	resp := &ProductResponse{Product: product, Items: items}
+
+	if resp.Items == nil {
+		resp.Items = []models.ItemWithPrice{}
+	}
+	return resp
+}
+
+// Render is preprocessing before the response is marshalled
+func (rd *ProductResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
@@ -0,0 +1,63 @@
+package payloads
+
+import (
+	"net/http"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/go-chi/render"
+)
+
+// ScraperHealthResponse is the response payload for the ScrapeHealth data model.
+type ScraperHealthResponse struct {
+	*models.ScrapeHealth
+	HourSuccessRate float64               `json:"hour_success_rate"` // between 0 and 1, 0 if there were no scrapes
+	DaySuccessRate  float64               `json:"day_success_rate"`
+	RecentErrors    []models.ScrapeResult `json:"recent_errors"`
+	Breaker         string                `json:"breaker"` // state of the host's circuit breaker
+}
+
+// NewScraperHealthResponse generate a Response for a ScrapeHealth object
+func NewScraperHealthResponse(health *models.ScrapeHealth, recentErrors []models.ScrapeResult, breaker string) *ScraperHealthResponse {
+	resp := &ScraperHealthResponse{
+		ScrapeHealth:    health,
+		HourSuccessRate: successRate(health.HourSuccesses, health.HourTotal),
+		DaySuccessRate:  successRate(health.DaySuccesses, health.DayTotal),
+		RecentErrors:    recentErrors,
+		Breaker:         breaker,
+	}
+
+	if resp.RecentErrors == nil {
+		resp.RecentErrors = []models.ScrapeResult{}
+	}
+	return resp
+}
+
+// NewScraperHealthListResponse generates a list of renders for the health of every host,
+// with the recent errors and the circuit breaker state of each host
+func NewScraperHealthListResponse(health []models.ScrapeHealth, recentErrors []models.ScrapeResult, breakerState func(host string) string) []render.Renderer {
+	errorsByHost := make(map[string][]models.ScrapeResult)
+	for _, result := range recentErrors {
+		errorsByHost[result.Host] = append(errorsByHost[result.Host], result)
+	}
+
+	list := []render.Renderer{}
+	for i := range health {
+		list = append(list, NewScraperHealthResponse(&health[i], errorsByHost[health[i].Host], breakerState(health[i].Host)))
+	}
+
+	return list
+}
+
+// successRate returns the share of successful scrapes
+func successRate(successes int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(successes) / float64(total)
+}
+
+// Render is preprocessing before the response is marshalled
+func (rd *ScraperHealthResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
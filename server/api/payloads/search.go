@@ -0,0 +1,44 @@
+package payloads
+
+import (
+	"net/http"
+
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+)
+
+// SearchResultResponse is the response payload for a product found by a store search.
+// Its URL can be posted to /api/item/url as is to track the product.
+type SearchResultResponse struct {
+	*scraper.SearchResult
+	ItemID *uuid.UUID `json:"item_id,omitempty"` // only if the product is already tracked
+}
+
+// NewSearchResultResponse generate a Response for a SearchResult object
+func NewSearchResultResponse(result *scraper.SearchResult) *SearchResultResponse {
+	resp := &SearchResultResponse{SearchResult: result}
+
+	return resp
+}
+
+// NewSearchResultListResponse generates a list of renders for SearchResults,
+// with the id of the tracked items, which tracked maps by store key
+func NewSearchResultListResponse(results []scraper.SearchResult, tracked map[string]uuid.UUID) []render.Renderer {
+	list := []render.Renderer{}
+	for i := range results {
+		resp := NewSearchResultResponse(&results[i])
+		if itemID, ok := tracked[results[i].StoreKey]; ok {
+			resp.ItemID = &itemID
+		}
+		list = append(list, resp)
+	}
+
+	return list
+}
+
+// Render is preprocessing before the response is marshalled
+func (rd *SearchResultResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// Pre-processing before a response is marshalled and sent across the wire
+	return nil
+}
@@ -1,6 +1,7 @@
 package payloads
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/UN0wen/pricewatch-vn/server/api/models"
@@ -8,6 +9,19 @@ import (
 	"github.com/google/uuid"
 )
 
+// UserItemRequest is the request payload for the UserItem data model
+type UserItemRequest struct {
+	UserItem *models.UserItem `json:"user_item"`
+}
+
+// Bind is the postprocessing for the UserItemRequest after the request is unmarshalled
+func (a *UserItemRequest) Bind(r *http.Request) error {
+	if a.UserItem == nil {
+		return errors.New("missing required UserItem fields")
+	}
+	return nil
+}
+
 // UserItemResponse is the response payload for the ItemPrice data model.
 type UserItemResponse struct {
 	UserItem *models.UserItem `json:"user_item"`
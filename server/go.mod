@@ -1,12 +1,12 @@
 // +heroku goVersion go1.15
 
-
 module github.com/UN0wen/pricewatch-vn/server
 
 go 1.15
 
 require (
 	github.com/PuerkitoBio/goquery v1.6.0
+	github.com/andybalholm/cascadia v1.1.0
 	github.com/asaskevich/govalidator v0.0.0-20200907205600-7a23bdc65eef
 	github.com/auth0/go-jwt-middleware v0.0.0-20201030150249-d783b5c46b39
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
@@ -33,7 +33,7 @@ require (
 	golang.org/x/mod v0.4.0 // indirect
 	golang.org/x/tools v0.0.0-20201228204837-84d76fe3206d // indirect
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+	gopkg.in/yaml.v2 v2.4.0
 	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
 	honnef.co/go/tools v0.1.0 // indirect
 )
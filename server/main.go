@@ -35,6 +35,26 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// Exchange rates are only needed to convert prices, so the server can start without them
+	if provider, err := services.NewRateProvider(); err != nil {
+		utils.Sugar.Infof("%s", err)
+	} else if err := services.UpdateRates(provider); err != nil {
+		utils.Sugar.Infof("Could not update the exchange rates: %s", err)
+	}
+
+	// Items that were added before product grouping, or whose grouping failed, are grouped at startup
+	if err := services.GroupAll(); err != nil {
+		utils.Sugar.Infof("Could not group the items into products: %s", err)
+	}
+
+	// Images are hashed to suggest duplicates, the items added before hashing or whose image failed are hashed
+	// in the background, as downloading every image would hold up the server
+	go func() {
+		if err := services.HashAll(); err != nil {
+			utils.Sugar.Infof("Could not hash the item images: %s", err)
+		}
+	}()
+
 	err := services.UpdateAll()
 	utils.CheckError(err)
 	utils.Sugar.Infof("Started server on port %s", utils.ServerPort)
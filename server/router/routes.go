@@ -24,6 +24,15 @@ func createUserRoutes(r *chi.Mux) {
 		r.With(middleware.Authenticate).With(controllers.SessionCtx).Get("/item/{itemID}", controllers.GetUserItem) //
 		r.With(middleware.Authenticate).With(controllers.SessionCtx).Get("/items", controllers.GetUserItems)        //
 		r.With(middleware.Authenticate).With(controllers.SessionCtx).Post("/item", controllers.CreateUserItem)      //
+		r.With(middleware.Authenticate).With(controllers.SessionCtx).Put("/item/{itemID}", controllers.UpdateUserItem)
+
+		// Subscriptions
+		r.With(middleware.Authenticate).With(controllers.SessionCtx).Post("/item/{itemID}/subscription", controllers.Subscribe)
+		r.With(middleware.Authenticate).With(controllers.SessionCtx).Delete("/item/{itemID}/subscription", controllers.Unsubscribe)
+
+		// Duplicates
+		r.With(middleware.Authenticate).With(controllers.SessionCtx).Get("/item/{itemID}/duplicates", controllers.GetItemDuplicates)
+		r.With(middleware.Authenticate).With(controllers.SessionCtx).Put("/item/{itemID}/duplicates/{duplicateID}", controllers.DecideItemDuplicate)
 	})
 }
 
@@ -39,10 +48,36 @@ func createItemRoutes(r *chi.Mux) {
 		r.Get("/{itemID}", controllers.GetItemWithPrice)                                                         // Get /users
 		r.Get("/{itemID}/price", controllers.GetPrice)
 		r.Get("/{itemID}/prices", controllers.GetPrices)
+		r.Get("/{itemID}/variants", controllers.GetVariants)
+		r.Get("/{itemID}/offers", controllers.GetOffers)
+		r.Get("/{itemID}/offers/history", controllers.GetOfferHistory)
+		r.Get("/{itemID}/product", controllers.GetItemProduct)
 		r.Post("/validate", controllers.ValidateURL)
 	})
 }
 
+func createProductRoutes(r *chi.Mux) {
+	r.Route("/api/product", func(r chi.Router) {
+		r.Get("/{productID}", controllers.GetProduct)
+	})
+}
+
+func createStoreRoutes(r *chi.Mux) {
+	r.Route("/api/stores", func(r chi.Router) {
+		// Searches scrape the stores, like creating an item from an URL
+		r.With(middleware.Authenticate).With(controllers.SessionCtx).Get("/search", controllers.SearchStores)
+	})
+}
+
+func createAdminRoutes(r *chi.Mux) {
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(middleware.Authenticate, controllers.SessionCtx, controllers.AdminCtx)
+		r.Get("/scrapers", controllers.GetScraperHealth)
+		r.Get("/duplicates", controllers.GetDuplicates)
+		r.Put("/duplicates/{itemID}/{duplicateID}", controllers.DecideDuplicate)
+	})
+}
+
 func createAuthRoutes(r *chi.Mux) {
 	r.Post("/api/signup", controllers.CreateUser)
 	r.Post("/api/login", controllers.LoginUser)
@@ -71,7 +106,10 @@ func NewRouter() *chi.Mux {
 	// Create API routes
 	createUserRoutes(router)
 	createItemRoutes(router)
+	createProductRoutes(router)
+	createStoreRoutes(router)
 	createAuthRoutes(router)
+	createAdminRoutes(router)
 
 	spa := spaHandler{staticPath: "build", indexPath: "index.html"}
 	router.Handle("/*", spa)
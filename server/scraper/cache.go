@@ -0,0 +1,318 @@
+package scraper
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/pkg/errors"
+)
+
+// Values of the X-Cache header of the responses of the CachingFetcher
+const (
+	CacheHit         = "hit"         // the body was downloaded recently, so no request was sent
+	CacheRevalidated = "revalidated" // the store responded 304 Not Modified
+	CacheMiss        = "miss"        // the body was downloaded
+)
+
+// CachingFetcher is a Fetcher that keeps the recent bodies of GET requests on disk, so the pages
+// downloaded by ScrapeInfo, ScrapePrice and the other scraper methods for the same URL are shared.
+// Bodies younger than fresh are served without a request. Older bodies are revalidated with
+// If-None-Match and If-Modified-Since, and a 304 serves the cached body.
+// At most size bodies are kept, the least recently used are removed first.
+type CachingFetcher struct {
+	fetcher Fetcher
+	dir     string
+	fresh   time.Duration
+	size    int
+	mutex   sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry is a cached body. Its metadata is saved next to it in the cache folder.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"last_modified"`
+	ContentType  string    `json:"content_type"`
+	Stored       time.Time `json:"stored"` // when the body was downloaded or last revalidated
+	used         time.Time
+	docOnce      sync.Once
+	doc          *goquery.Document
+	docErr       error
+}
+
+// NewCachingFetcher wraps fetcher with a cache of size bodies in dir.
+// The bodies left in dir by a previous run are reused.
+func NewCachingFetcher(fetcher Fetcher, dir string, fresh time.Duration, size int) (f *CachingFetcher, err error) {
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot create the page cache folder %s", dir)
+		return
+	}
+
+	if size < 1 {
+		size = 1
+	}
+
+	f = &CachingFetcher{fetcher: fetcher, dir: dir, fresh: fresh, size: size, entries: make(map[string]*cacheEntry)}
+	f.load()
+	return
+}
+
+// Do serves a GET request from the cache if its body is fresh or unchanged, and sends it otherwise
+func (f *CachingFetcher) Do(req *http.Request) (resp *http.Response, err error) {
+	if req.Method != http.MethodGet {
+		return f.fetcher.Do(req)
+	}
+
+	key := cacheKey(req)
+	entry, stored := f.entry(key)
+
+	if entry != nil && time.Since(stored) < f.fresh {
+		return f.respond(req, key, entry, CacheHit)
+	}
+
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err = f.fetcher.Do(req)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+
+		f.mutex.Lock()
+		entry.Stored = time.Now()
+		f.mutex.Unlock()
+		f.saveMeta(key, entry)
+		return f.respond(req, key, entry, CacheRevalidated)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot read the response of %s", req.URL.String())
+		return nil, err
+	}
+
+	entry = &cacheEntry{
+		URL:          req.URL.String(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+		Stored:       time.Now(),
+	}
+	f.store(key, entry, body)
+
+	resp.Header.Set("X-Cache", CacheMiss)
+	resp.Body = &cachedBody{Reader: bytes.NewReader(body), entry: entry}
+	return
+}
+
+// cachedBody is the body of a response of the CachingFetcher.
+// GetDocument uses its entry to parse a page only once for as long as it is unchanged.
+type cachedBody struct {
+	*bytes.Reader
+	entry *cacheEntry
+}
+
+// Close does nothing, the body is in memory
+func (b *cachedBody) Close() error {
+	return nil
+}
+
+// document returns the parsed body, parsing it on the first call
+func (b *cachedBody) document() (*goquery.Document, error) {
+	b.entry.docOnce.Do(func() {
+		b.entry.doc, b.entry.docErr = goquery.NewDocumentFromReader(b.Reader)
+	})
+	return b.entry.doc, b.entry.docErr
+}
+
+// respond serves a cached body
+func (f *CachingFetcher) respond(req *http.Request, key string, entry *cacheEntry, cache string) (resp *http.Response, err error) {
+	body, err := ioutil.ReadFile(f.path(key, ".body"))
+	if err != nil {
+		// The body was removed from the folder, download it again
+		f.remove(key)
+		req.Header.Del("If-None-Match")
+		req.Header.Del("If-Modified-Since")
+		return f.Do(req)
+	}
+
+	resp = &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{},
+		Body:          &cachedBody{Reader: bytes.NewReader(body), entry: entry},
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	resp.Header.Set("Content-Type", entry.ContentType)
+	resp.Header.Set("X-Cache", cache)
+	return
+}
+
+// cacheKey identifies the body of a request. The Accept header is part of it,
+// as some stores serve HTML and JSON from the same URL.
+func cacheKey(req *http.Request) string {
+	hash := sha1.Sum([]byte(req.URL.String() + " " + req.Header.Get("Accept")))
+	return hex.EncodeToString(hash[:])
+}
+
+// path returns the file of a cached body or of its metadata
+func (f *CachingFetcher) path(key string, ext string) string {
+	return filepath.Join(f.dir, key+ext)
+}
+
+// entry returns the cached entry for key, loading its metadata from the folder if it isn't in memory,
+// with the time it was stored. Stored is read under the mutex, as a revalidation can update it.
+func (f *CachingFetcher) entry(key string) (entry *cacheEntry, stored time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	entry, ok := f.entries[key]
+	if !ok {
+		entry = f.loadMeta(key)
+		if entry == nil {
+			return
+		}
+		f.entries[key] = entry
+		f.evict()
+	}
+
+	entry.used = time.Now()
+	return entry, entry.Stored
+}
+
+// loadMeta reads the metadata of a body from the folder, nil if it is missing or unreadable
+func (f *CachingFetcher) loadMeta(key string) *cacheEntry {
+	data, err := ioutil.ReadFile(f.path(key, ".json"))
+	if err != nil {
+		return nil
+	}
+
+	entry := &cacheEntry{}
+	if err = json.Unmarshal(data, entry); err != nil {
+		return nil
+	}
+	return entry
+}
+
+// store saves a downloaded body, replacing the previous one
+func (f *CachingFetcher) store(key string, entry *cacheEntry, body []byte) {
+	err := ioutil.WriteFile(f.path(key, ".body"), body, 0644)
+	if err != nil {
+		utils.Sugar.Infof("Cannot cache the body of %s: %s", entry.URL, err)
+		return
+	}
+	f.saveMeta(key, entry)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	entry.used = time.Now()
+	f.entries[key] = entry
+	f.evict()
+}
+
+// saveMeta saves the metadata of an entry next to its body
+func (f *CachingFetcher) saveMeta(key string, entry *cacheEntry) {
+	f.mutex.Lock()
+	data, err := json.Marshal(entry)
+	f.mutex.Unlock()
+
+	if err == nil {
+		err = ioutil.WriteFile(f.path(key, ".json"), data, 0644)
+	}
+	if err != nil {
+		utils.Sugar.Infof("Cannot cache the metadata of %s: %s", entry.URL, err)
+	}
+}
+
+// evict removes the least recently used entries above the cache size. The mutex must be held.
+func (f *CachingFetcher) evict() {
+	for len(f.entries) > f.size {
+		var oldest string
+		for key, entry := range f.entries {
+			if oldest == "" || entry.used.Before(f.entries[oldest].used) {
+				oldest = key
+			}
+		}
+
+		delete(f.entries, oldest)
+		os.Remove(f.path(oldest, ".body"))
+		os.Remove(f.path(oldest, ".json"))
+	}
+}
+
+// remove drops an entry from the cache
+func (f *CachingFetcher) remove(key string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.entries, key)
+	os.Remove(f.path(key, ".body"))
+	os.Remove(f.path(key, ".json"))
+}
+
+// load indexes the bodies left in the folder by a previous run, so they count towards the cache size.
+// The most recent ones are kept up to the size, the others are removed.
+func (f *CachingFetcher) load() {
+	files, err := ioutil.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+
+	var metas []os.FileInfo
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), ".json") {
+			metas = append(metas, file)
+		}
+	}
+
+	sort.Slice(metas, func(i, j int) bool {
+		return metas[i].ModTime().After(metas[j].ModTime())
+	})
+
+	for _, meta := range metas {
+		key := strings.TrimSuffix(meta.Name(), ".json")
+		if len(f.entries) < f.size {
+			if entry := f.loadMeta(key); entry != nil {
+				entry.used = meta.ModTime()
+				f.entries[key] = entry
+				continue
+			}
+		}
+
+		os.Remove(f.path(key, ".body"))
+		os.Remove(f.path(key, ".json"))
+	}
+}
@@ -0,0 +1,134 @@
+package scraper_test
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+	"github.com/UN0wen/pricewatch-vn/server/scraper/scrapertest"
+)
+
+func TestCachingFetcher(t *testing.T) {
+	page, _ := url.Parse("https://mia.vn/vali-keo-mia-gold-20-inch.html")
+	other, _ := url.Parse("https://www.nguyenkim.com/may-giat-lg-inverter-8-5-kg-fv1408s4w.html")
+
+	t.Run("fresh", func(t *testing.T) {
+		server := scrapertest.NewServer(fixtureRoot)
+		t.Cleanup(server.Close)
+
+		fetcher, err := scraper.NewCachingFetcher(server.Fetcher(), t.TempDir(), time.Hour, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		first, err := scraper.GetDocument(fetcher, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := scraper.GetDocument(fetcher, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if server.Requests() != 1 {
+			t.Errorf("got %d requests, want 1", server.Requests())
+		}
+		if first != second {
+			t.Error("the cached page was parsed again")
+		}
+	})
+
+	t.Run("revalidated", func(t *testing.T) {
+		server := scrapertest.NewServer(fixtureRoot)
+		t.Cleanup(server.Close)
+
+		dir := t.TempDir()
+		fetcher, err := scraper.NewCachingFetcher(server.Fetcher(), dir, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		first, err := scraper.GetDocument(fetcher, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := scraper.GetDocument(fetcher, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if server.Requests() != 2 {
+			t.Errorf("got %d requests, want 2", server.Requests())
+		}
+		if first != second {
+			t.Error("the unchanged page was parsed again")
+		}
+
+		// A new fetcher reuses the bodies left in the folder
+		fetcher, err = scraper.NewCachingFetcher(server.Fetcher(), dir, time.Hour, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = scraper.GetDocument(fetcher, page); err != nil {
+			t.Fatal(err)
+		}
+		if server.Requests() != 2 {
+			t.Errorf("got %d requests after a restart, want 2", server.Requests())
+		}
+	})
+
+	t.Run("evicted", func(t *testing.T) {
+		server := scrapertest.NewServer(fixtureRoot)
+		t.Cleanup(server.Close)
+
+		dir := t.TempDir()
+		fetcher, err := scraper.NewCachingFetcher(server.Fetcher(), dir, time.Hour, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, u := range []*url.URL{page, other, page} {
+			if _, err = scraper.GetDocument(fetcher, u); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if server.Requests() != 3 {
+			t.Errorf("got %d requests, want 3", server.Requests())
+		}
+
+		files, _ := ioutil.ReadDir(dir)
+		if len(files) != 2 {
+			t.Errorf("got %d files in the cache, want the body and metadata of 1 page", len(files))
+		}
+	})
+	t.Run("evicted after a restart", func(t *testing.T) {
+		server := scrapertest.NewServer(fixtureRoot)
+		t.Cleanup(server.Close)
+
+		dir := t.TempDir()
+		fetcher, err := scraper.NewCachingFetcher(server.Fetcher(), dir, time.Hour, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = scraper.GetDocument(fetcher, page); err != nil {
+			t.Fatal(err)
+		}
+
+		// The body left by the first fetcher counts towards the size of the second
+		fetcher, err = scraper.NewCachingFetcher(server.Fetcher(), dir, time.Hour, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = scraper.GetDocument(fetcher, other); err != nil {
+			t.Fatal(err)
+		}
+
+		files, _ := ioutil.ReadDir(dir)
+		if len(files) != 2 {
+			t.Errorf("got %d files in the cache, want the body and metadata of 1 page", len(files))
+		}
+	})
+}
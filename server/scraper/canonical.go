@@ -0,0 +1,140 @@
+package scraper
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// trackingParams are query parameters added by stores and ad networks that don't change the product
+var trackingParams = map[string]bool{
+	"spm":            true,
+	"scm":            true,
+	"clicktrackinfo": true,
+	"fbclid":         true,
+	"gclid":          true,
+	"zarsrc":         true,
+	"gidzl":          true,
+	"src":            true,
+	"ref":            true,
+	"from":           true,
+	"search":         true,
+	"keyword":        true,
+	"position":       true,
+	"mp":             true,
+	"_gl":            true,
+	"sp_atk":         true,
+	"xptdk":          true,
+}
+
+// trackingPrefixes are prefixes of tracking query parameters (e.g. utm_source)
+var trackingPrefixes = []string{
+	"utm_",
+	"itm_",
+	"laz_",
+}
+
+// AliasScraper is implemented by scrapers whose store is reachable at more than one host.
+// The "www." and "m." variants of the scraper's host don't need to be listed.
+type AliasScraper interface {
+	Aliases() []string
+}
+
+// Canonicalizer is implemented by scrapers that can read their store's product id from an URL
+type Canonicalizer interface {
+	// ProductID returns the store's stable id for the product at path, or an empty string
+	ProductID(path *url.URL) string
+}
+
+// CanonicalURL is an item URL in the form used for scraper lookup and item de-duplication
+type CanonicalURL struct {
+	URL       *url.URL // https URL on the scraper's host, without tracking parameters
+	Host      string   // host of the scraper that handles the URL
+	ProductID string   // the store's product id, empty if the scraper doesn't know it
+	Key       string   // the same product always has the same key
+}
+
+// isTrackingParam checks if a query parameter is used for tracking
+func isTrackingParam(param string) bool {
+	param = strings.ToLower(param)
+	if trackingParams[param] {
+		return true
+	}
+
+	for _, prefix := range trackingPrefixes {
+		if strings.HasPrefix(param, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostVariants returns host with and without its "www." and "m." prefixes
+func hostVariants(host string) []string {
+	bare := strings.TrimPrefix(strings.TrimPrefix(host, "www."), "m.")
+	return []string{host, bare, "www." + bare, "m." + bare}
+}
+
+// resolveHost returns the registered host for an alias of it
+func (s *scraper) resolveHost(host string) string {
+	host = strings.ToLower(host)
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+
+	for _, variant := range hostVariants(host) {
+		if _, ok := s.Scrapers[variant]; ok {
+			return variant
+		}
+		if registered, ok := s.aliases[variant]; ok {
+			return registered
+		}
+	}
+	return host
+}
+
+// Canonicalize maps the URL to its scraper's host, strips tracking parameters and fragments
+// and asks the scraper for the store's product id.
+func (s *scraper) Canonicalize(rawURL string) (canonical CanonicalURL, err error) {
+	path, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL provided")
+		return
+	}
+
+	if path.Host == "" {
+		err = errors.New("Invalid URL provided: missing host")
+		return
+	}
+
+	query := path.Query()
+	for param := range query {
+		if isTrackingParam(param) {
+			query.Del(param)
+		}
+	}
+
+	canonical.Host = s.resolveHost(path.Host)
+	canonical.URL = &url.URL{
+		Scheme:   "https",
+		Host:     canonical.Host,
+		Path:     path.Path,
+		RawQuery: query.Encode(),
+	}
+
+	found, _ := s.Get(canonical.Host)
+	if canonicalizer, ok := found.(Canonicalizer); ok {
+		canonical.ProductID = canonicalizer.ProductID(canonical.URL)
+	}
+
+	if canonical.ProductID != "" {
+		canonical.Key = canonical.Host + "/" + canonical.ProductID
+	} else {
+		canonical.Key = canonical.Host + strings.TrimSuffix(canonical.URL.EscapedPath(), "/")
+		if canonical.URL.RawQuery != "" {
+			canonical.Key += "?" + canonical.URL.RawQuery
+		}
+	}
+	return
+}
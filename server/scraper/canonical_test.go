@@ -0,0 +1,84 @@
+package scraper_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+)
+
+// aliasConfig is a store reachable at a second host
+const aliasConfig = `host: fptshop.com.vn
+aliases:
+  - fpt.shop
+currency: VND
+name:
+  selector: h1
+description:
+  selector: meta[name="description"]
+  attribute: content
+image:
+  selector: meta[property="og:image"]
+  attribute: content
+price:
+  selector: .st-price-main
+`
+
+func TestCanonicalize(t *testing.T) {
+	configRoot := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(configRoot, "fptshop.yaml"), []byte(aliasConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fetcher, err := scraper.NewHTTPFetcher(scraper.FetcherConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := scraper.New(fetcher, configRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		url  string
+		want string // canonical URL
+		key  string
+	}{
+		{"product id", "https://tiki.vn/sach-nha-gia-kim-p123456.html", "https://tiki.vn/sach-nha-gia-kim-p123456.html", "tiki.vn/123456"},
+		{"renamed product", "https://tiki.vn/nha-gia-kim-tai-ban-p123456.html", "https://tiki.vn/nha-gia-kim-tai-ban-p123456.html", "tiki.vn/123456"},
+		{"tracking parameters", "https://tiki.vn/sach-nha-gia-kim-p123456.html?spm=a2o4n.home&utm_source=facebook&src=search&gclid=abc", "https://tiki.vn/sach-nha-gia-kim-p123456.html", "tiki.vn/123456"},
+		{"fragment and http", "http://tiki.vn/sach-nha-gia-kim-p123456.html#reviews", "https://tiki.vn/sach-nha-gia-kim-p123456.html", "tiki.vn/123456"},
+		{"mobile host", "https://m.tiki.vn/sach-nha-gia-kim-p123456.html", "https://tiki.vn/sach-nha-gia-kim-p123456.html", "tiki.vn/123456"},
+		{"www host", "https://lazada.vn/products/dien-thoai-samsung-galaxy-a52-i1234567-s7654321.html?laz_trackid=1", "https://www.lazada.vn/products/dien-thoai-samsung-galaxy-a52-i1234567-s7654321.html", "www.lazada.vn/1234567"},
+		{"other seller", "https://www.lazada.vn/products/dien-thoai-samsung-galaxy-a52-i1234567-s1111111.html", "https://www.lazada.vn/products/dien-thoai-samsung-galaxy-a52-i1234567-s1111111.html", "www.lazada.vn/1234567"},
+		{"shop and item ids", "https://shopee.vn/Tai-nghe-Bluetooth-TWS-i12-i.111.222?sp_atk=x&xptdk=y", "https://shopee.vn/Tai-nghe-Bluetooth-TWS-i12-i.111.222", "shopee.vn/111.222"},
+		{"config alias", "https://fpt.shop/may-tinh-xach-tay/asus-vivobook-a415ea?utm_medium=cpc", "https://fptshop.com.vn/may-tinh-xach-tay/asus-vivobook-a415ea", "fptshop.com.vn/may-tinh-xach-tay/asus-vivobook-a415ea"},
+		{"product query kept", "https://www.example-store.vn/product?id=42&fbclid=abc", "https://www.example-store.vn/product?id=42", "www.example-store.vn/product?id=42"},
+		{"trailing slash", "https://www.example-store.vn/products/binh-giu-nhiet-500ml/", "https://www.example-store.vn/products/binh-giu-nhiet-500ml/", "www.example-store.vn/products/binh-giu-nhiet-500ml"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			canonical, err := s.Canonicalize(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := canonical.URL.String(); got != tc.want {
+				t.Errorf("URL: got %s, want %s", got, tc.want)
+			}
+			if canonical.Key != tc.key {
+				t.Errorf("Key: got %s, want %s", canonical.Key, tc.key)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "tiki.vn/sach-nha-gia-kim-p123456.html", "://tiki.vn"} {
+		if _, err := s.Canonicalize(invalid); err == nil {
+			t.Errorf("Canonicalize(%q): got no error", invalid)
+		}
+	}
+}
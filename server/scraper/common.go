@@ -1,28 +1,46 @@
 package scraper
 
 import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
 	"github.com/pkg/errors"
 )
 
 // ConfigRoot is the folder where scraper configs are stored
 const ConfigRoot = "./scraper/configs/"
 
+// DefaultCurrency is the currency of items whose store doesn't say
+const DefaultCurrency = "VND"
+
+// currencyExponents is the number of digits after the decimal point of the currencies that don't have 2 (ISO 4217)
+var currencyExponents = map[string]int{
+	"VND": 0, "JPY": 0, "KRW": 0, "CLP": 0, "ISK": 0, "PYG": 0, "UGX": 0, "XAF": 0, "XOF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
 // InStockHTTPS is the standard in stock enum in HTTPS
 const InStockHTTPS = "https://schema.org/InStock"
 
 // InStockHTTP is the standard in stock enum in HTTP
 const InStockHTTP = "http://schema.org/InStock"
 
-var client = &http.Client{}
-
 type scraper struct {
 	Scrapers map[string]Scraper
+	Generic  GenericScraper // used for hosts without a scraper
+	Fetcher  Fetcher
+	aliases  map[string]string
 }
 
 // Scraper is an interface implemented by all Scrapers
@@ -32,10 +50,77 @@ type Scraper interface {
 	GetHost() (host string)
 }
 
-// Register scraper singletons here
-var scrapers = []Scraper{
-	LazadaScraper{},
-	TikiScraper{},
+// ContextScraper is implemented by scrapers whose requests can be cancelled, or given a deadline, through a context.
+// Use ScrapeInfo and ScrapePrice to scrape with a context through any Scraper.
+type ContextScraper interface {
+	Scraper
+	ScrapeInfoContext(ctx context.Context, path *url.URL) (item models.Item, err error)
+	ScrapePriceContext(ctx context.Context, item models.Item) (itemPrice models.ItemPrice, err error)
+}
+
+// ScrapeInfo scrapes the item at path with s.
+// ctx only cancels the scrape if s implements ContextScraper.
+func ScrapeInfo(ctx context.Context, s Scraper, path *url.URL) (item models.Item, err error) {
+	if contextScraper, ok := s.(ContextScraper); ok {
+		return contextScraper.ScrapeInfoContext(ctx, path)
+	}
+	return s.ScrapeInfo(path)
+}
+
+// ScrapePrice scrapes the price of an item with s.
+// ctx only cancels the scrape if s implements ContextScraper.
+func ScrapePrice(ctx context.Context, s Scraper, item models.Item) (itemPrice models.ItemPrice, err error) {
+	if contextScraper, ok := s.(ContextScraper); ok {
+		return contextScraper.ScrapePriceContext(ctx, item)
+	}
+	return s.ScrapePrice(item)
+}
+
+// OfferScraper is implemented by scrapers of comparison sites and marketplaces,
+// where a single product page lists the offers of many shops or sellers.
+// ctx cancels the scrape, like the methods of ContextScraper.
+type OfferScraper interface {
+	Scraper
+	ScrapeOffers(ctx context.Context, item models.Item) (offers []models.ItemOffer, err error)
+}
+
+// offerPricer is implemented by the scrapers of comparison sites whose price is the best of their offers,
+// so ScrapeListing reads the offers once instead of scraping the price on its own
+type offerPricer interface {
+	OfferScraper
+	priceOf(offers []models.ItemOffer) (itemPrice models.ItemPrice, err error)
+}
+
+// VariantScraper is implemented by scrapers of stores that sell an item in several variants
+// (e.g. 128GB and 256GB), each with its own SKU and price.
+// prices has one entry for each of the variants, with the variant's SKU set. ctx cancels the scrape.
+type VariantScraper interface {
+	Scraper
+	ScrapeVariants(ctx context.Context, item models.Item) (variants []models.ItemVariant, prices []models.ItemPrice, err error)
+}
+
+// PromotionScraper is implemented by scrapers of stores that publish time-limited promotions, such as flash sales.
+// promotions is empty if the item isn't on promotion. ctx cancels the scrape.
+type PromotionScraper interface {
+	Scraper
+	ScrapePromotions(ctx context.Context, item models.Item) (promotions []models.ItemPromotion, err error)
+}
+
+// Register scraper singletons here.
+// Every scraper receives the Fetcher it downloads pages with.
+func newScrapers(fetcher Fetcher) []Scraper {
+	return []Scraper{
+		LazadaScraper{Fetcher: fetcher},
+		TikiScraper{Fetcher: fetcher},
+		ShopeeScraper{Fetcher: fetcher},
+		SendoScraper{Fetcher: fetcher},
+		TGDDScraper{Fetcher: fetcher},
+		DMXScraper{Fetcher: fetcher},
+		NguyenKimScraper{Fetcher: fetcher},
+		MiaScraper{Fetcher: fetcher},
+		VinabookScraper{Fetcher: fetcher},
+		VatgiaScraper{Fetcher: fetcher},
+	}
 }
 
 // Singleton reference to the model layer.
@@ -49,42 +134,156 @@ var once sync.Once
 // It returns the reference to the scraper instance.
 func Instance() *scraper {
 	once.Do(func() {
-		var err error
-		scraperMap := make(map[string]Scraper)
+		config := fetcherConfig()
+		httpFetcher, err := NewHTTPFetcher(config)
 		if err != nil {
-			err = errors.Wrapf(err, "Could not set up Scraper configs")
+			err = errors.Wrapf(err, "Could not set up the Fetcher")
+			utils.Sugar.Error(err)
 			return
 		}
 
-		for _, scraper := range scrapers {
-			scraperMap[scraper.GetHost()] = scraper
+		var fetcher Fetcher = httpFetcher
+		if utils.ScraperRobots != "false" {
+			ttl, _ := strconv.Atoi(utils.ScraperRobotsTTL)
+			fetcher = NewRobotsFetcher(httpFetcher, httpFetcher.UserAgent(), time.Duration(ttl)*time.Minute)
 		}
 
-		instance = &scraper{Scrapers: scraperMap}
+		if utils.ScraperCache != "false" {
+			fresh, _ := strconv.Atoi(utils.ScraperCacheFresh)
+			size, _ := strconv.Atoi(utils.ScraperCacheSize)
+			cache, err := NewCachingFetcher(fetcher, utils.ScraperCacheDir, time.Duration(fresh)*time.Second, size)
+			if err != nil {
+				// Scrape without a cache rather than not at all
+				utils.Sugar.Error(err)
+			} else {
+				fetcher = cache
+			}
+		}
+
+		instance, err = New(fetcher, ConfigRoot)
+		if err != nil {
+			utils.Sugar.Error(err)
+		}
 	})
 
 	return instance
 }
 
-// GetDocument returns the goquery document from an URL
-func GetDocument(sanitized *url.URL) (doc *goquery.Document, err error) {
-	req, err := http.NewRequest("GET", "https://"+sanitized.Host+sanitized.Path, nil)
+// New creates the scrapers for every store, downloading pages with fetcher.
+// The scraper configs in configRoot are loaded after the built-in scrapers.
+func New(fetcher Fetcher, configRoot string) (s *scraper, err error) {
+	scraperMap := make(map[string]Scraper)
+	configScrapers, err := LoadConfigs(configRoot, fetcher)
 	if err != nil {
-		errors.Wrapf(err, "The external server can't be reached")
+		err = errors.Wrapf(err, "Could not set up Scraper configs")
 		return
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
-	resp, err := client.Do(req)
+	for _, scraper := range newScrapers(fetcher) {
+		scraperMap[scraper.GetHost()] = scraper
+	}
+
+	// Configs are loaded last so they can fix a built-in scraper without recompiling
+	for _, scraper := range configScrapers {
+		if _, ok := scraperMap[scraper.GetHost()]; ok {
+			utils.Sugar.Infof("Scraper config for %s replaces the built-in scraper", scraper.GetHost())
+		}
+		scraperMap[scraper.GetHost()] = scraper
+	}
+
+	aliases := make(map[string]string)
+	for host, scraper := range scraperMap {
+		if aliasScraper, ok := scraper.(AliasScraper); ok {
+			for _, alias := range aliasScraper.Aliases() {
+				aliases[alias] = host
+			}
+		}
+	}
+
+	s = &scraper{
+		Scrapers: scraperMap,
+		Generic:  GenericScraper{Fetcher: fetcher},
+		Fetcher:  fetcher,
+		aliases:  aliases,
+	}
+	return
+}
+
+// fetcherConfig reads the Fetcher's config from the environment
+func fetcherConfig() (config FetcherConfig) {
+	timeout, _ := strconv.Atoi(utils.ScraperTimeout)
+	backoff, _ := strconv.Atoi(utils.ScraperBackoff)
+	config.Timeout = time.Duration(timeout) * time.Second
+	config.Backoff = time.Duration(backoff) * time.Millisecond
+	config.Retries, _ = strconv.Atoi(utils.ScraperRetries)
+	config.RequestsPerSecond, _ = strconv.ParseFloat(utils.ScraperRate, 64)
+	config.Burst, _ = strconv.Atoi(utils.ScraperBurst)
+	config.UserAgent = utils.ScraperUserAgent
+	config.Headers = parseHeaders(utils.ScraperHeaders)
+	config.Proxy = utils.ScraperProxy
+	return
+}
+
+// parseHeaders reads headers written as "Name: value" pairs separated by "|".
+// Malformed pairs are logged and skipped.
+func parseHeaders(text string) (headers map[string]string) {
+	headers = make(map[string]string)
+	for _, pair := range strings.Split(text, "|") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			utils.Sugar.Errorf("Ignoring malformed scraper header %s", pair)
+			continue
+		}
+		headers[name] = strings.TrimSpace(parts[1])
+	}
+	return
+}
+
+// Get returns the scraper registered for host or one of its aliases.
+// If there is none, it returns the GenericScraper and generic is true.
+func (s *scraper) Get(host string) (found Scraper, generic bool) {
+	if found, ok := s.Scrapers[s.resolveHost(host)]; ok {
+		return found, false
+	}
+	return s.Generic, true
+}
+
+// GetDocument returns the goquery document from an URL
+func GetDocument(fetcher Fetcher, sanitized *url.URL) (doc *goquery.Document, err error) {
+	return GetDocumentContext(context.Background(), fetcher, sanitized)
+}
+
+// GetDocumentContext returns the goquery document from an URL, cancelling the download with ctx.
+// Under a context from WithDownloads, the page is only downloaded once.
+func GetDocumentContext(ctx context.Context, fetcher Fetcher, sanitized *url.URL) (doc *goquery.Document, err error) {
+	download := downloadsFrom(ctx).get("text/html " + sanitized.String())
+	download.once.Do(func() {
+		download.doc, download.err = getDocument(ctx, fetcher, sanitized)
+	})
+	return download.doc, download.err
+}
+
+// getDocument downloads and parses the page at sanitized
+func getDocument(ctx context.Context, fetcher Fetcher, sanitized *url.URL) (doc *goquery.Document, err error) {
+	resp, err := fetch(ctx, fetcher, sanitized.String(), "text/html")
 
 	if err != nil {
-		err = errors.Wrapf(err, "The external server can't be reached")
 		return
 	}
 
 	defer resp.Body.Close()
 
-	doc, err = goquery.NewDocumentFromReader(resp.Body)
+	// Pages from the CachingFetcher are only parsed once for as long as they are unchanged
+	if cached, ok := resp.Body.(*cachedBody); ok {
+		doc, err = cached.document()
+	} else {
+		doc, err = goquery.NewDocumentFromReader(resp.Body)
+	}
 
 	if err != nil {
 		err = errors.Wrapf(err, "Cannot parse shopping site's response HTML")
@@ -92,3 +291,274 @@ func GetDocument(sanitized *url.URL) (doc *goquery.Document, err error) {
 
 	return
 }
+
+// GetJSON fetches an URL from a store's JSON API and decodes the response into v
+func GetJSON(fetcher Fetcher, apiURL string, v interface{}) (err error) {
+	return GetJSONContext(context.Background(), fetcher, apiURL, v)
+}
+
+// GetJSONContext fetches an URL from a store's JSON API and decodes the response into v, cancelling the request with ctx.
+// Under a context from WithDownloads, the response is only downloaded once.
+func GetJSONContext(ctx context.Context, fetcher Fetcher, apiURL string, v interface{}) (err error) {
+	download := downloadsFrom(ctx).get("application/json " + apiURL)
+	download.once.Do(func() {
+		download.body, download.err = getBody(ctx, fetcher, apiURL)
+	})
+	if download.err != nil {
+		return download.err
+	}
+
+	err = json.Unmarshal(download.body, v)
+
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot parse shopping site's response JSON")
+	}
+
+	return
+}
+
+// getBody downloads the response of a store's JSON API
+func getBody(ctx context.Context, fetcher Fetcher, apiURL string) (body []byte, err error) {
+	resp, err := fetch(ctx, fetcher, apiURL, "application/json")
+
+	if err != nil {
+		return
+	}
+
+	defer resp.Body.Close()
+
+	body, err = ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot read shopping site's response")
+	}
+
+	return
+}
+
+// downloadsKey is the context key of the downloads during an update
+type downloadsKey struct{}
+
+// downloads holds the pages and API responses downloaded under a context from WithDownloads
+type downloads struct {
+	mutex sync.Mutex
+	byURL map[string]*download
+}
+
+// download is a downloaded page or API response. Requests for the same URL wait for the first download.
+type download struct {
+	once sync.Once
+	doc  *goquery.Document
+	body []byte
+	err  error
+}
+
+// WithDownloads returns a context under which every page and API response is downloaded once.
+// An update scrapes the price, offers, variants and promotions of an item with it,
+// so the store receives a single request for each of them instead of one per method.
+func WithDownloads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, downloadsKey{}, &downloads{byURL: make(map[string]*download)})
+}
+
+// downloadsFrom returns the downloads of ctx, or nil if it doesn't come from WithDownloads
+func downloadsFrom(ctx context.Context) *downloads {
+	d, _ := ctx.Value(downloadsKey{}).(*downloads)
+	return d
+}
+
+// get returns the download of key. Without downloads, every call returns a new one, which is downloaded again.
+func (d *downloads) get(key string) *download {
+	if d == nil {
+		return &download{}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	found, ok := d.byURL[key]
+	if !ok {
+		found = &download{}
+		d.byURL[key] = found
+	}
+	return found
+}
+
+// fetch sends a GET request for rawURL through fetcher. URLs without a scheme are fetched over https.
+func fetch(ctx context.Context, fetcher Fetcher, rawURL string, accept string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL %s", rawURL)
+		return
+	}
+
+	if req.URL.Scheme == "" {
+		req.URL.Scheme = "https"
+	}
+	req.Header.Set("Accept", accept)
+
+	return fetcher.Do(req)
+}
+
+// MinorUnits returns the number of digits after the decimal point of currency.
+// Prices are stored in the currency's minor unit, e.g. cents for USD and đồng for VND.
+// Items without a currency are in DefaultCurrency.
+func MinorUnits(currency string) int {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	if exponent, ok := currencyExponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// toMinor converts an amount of currency, as published by the stores' APIs, to the currency's minor unit
+func toMinor(amount float64, currency string) int64 {
+	return int64(math.Round(amount * math.Pow10(MinorUnits(currency))))
+}
+
+// parsePrice reads a displayed price (e.g. "12.990.000 ₫" in VND or "$12.99" in USD) in the minor unit of currency.
+// The last '.' or ',' is the decimal separator if it is followed by at most as many digits as the currency's minor unit has,
+// every other separator groups thousands. Currencies without a minor unit drop decimals such as 12990000.00.
+// It returns 0 if no price can be read.
+func parsePrice(text string, currency string) (price int64) {
+	exponent := MinorUnits(currency)
+	number := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' || r == ',' {
+			return r
+		}
+		return -1
+	}, text)
+	number = strings.Trim(number, ".,")
+
+	whole, fraction := number, ""
+	if i := strings.LastIndexAny(number, ".,"); i >= 0 {
+		if decimals := len(number) - i - 1; decimals <= exponent || (exponent == 0 && decimals <= 2) {
+			whole, fraction = number[:i], number[i+1:]
+		}
+	}
+
+	if len(fraction) > exponent {
+		fraction = fraction[:exponent]
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	digits := strings.NewReplacer(".", "", ",", "").Replace(whole) + fraction
+	price, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		price = 0
+	}
+	return
+}
+
+// parseCount reads a displayed count, such as a number of reviews, by dropping every non-digit character.
+// It returns 0 if no count can be read.
+func parseCount(text string) (count int64) {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, text)
+
+	count, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		count = 0
+	}
+	return
+}
+
+// setDiscount checks the original price of an item against its price,
+// and computes the discount if the store didn't publish it
+func setDiscount(itemPrice *models.ItemPrice) {
+	if itemPrice.OriginalPrice <= itemPrice.Price {
+		itemPrice.OriginalPrice = 0
+		itemPrice.Discount = 0
+		return
+	}
+
+	if itemPrice.Discount <= 0 || itemPrice.Discount >= 100 {
+		itemPrice.Discount = (itemPrice.OriginalPrice - itemPrice.Price) * 100 / itemPrice.OriginalPrice
+	}
+}
+
+// BestOffer returns the price of the cheapest available offer,
+// or of the cheapest offer if none of them are available
+func BestOffer(offers []models.ItemOffer) (itemPrice models.ItemPrice, err error) {
+	if len(offers) == 0 {
+		err = errors.New("No offers to choose a price from")
+		return
+	}
+
+	best := offers[0]
+	for _, offer := range offers[1:] {
+		switch {
+		case offer.Available && !best.Available:
+			best = offer
+		case offer.Available == best.Available && offer.Price < best.Price:
+			best = offer
+		}
+	}
+
+	itemPrice.Price = best.Price
+	itemPrice.Available = best.Available
+	itemPrice.Seller = best.Seller
+	return
+}
+
+// ScrapeListing scrapes the price of an item and, for scrapers that implement OfferScraper, the offer of every seller.
+// The headline price is then the best in-stock offer, keeping the other details read by ScrapePrice.
+// If only one of the two succeeds, its result is used.
+func ScrapeListing(ctx context.Context, s Scraper, item models.Item) (itemPrice models.ItemPrice, offers []models.ItemOffer, err error) {
+	if pricer, ok := s.(offerPricer); ok {
+		offers, err = pricer.ScrapeOffers(ctx, item)
+		if err != nil {
+			return
+		}
+
+		itemPrice, err = pricer.priceOf(offers)
+		return
+	}
+
+	itemPrice, err = ScrapePrice(ctx, s, item)
+	if ctx.Err() != nil {
+		return
+	}
+
+	offerScraper, ok := s.(OfferScraper)
+	if !ok {
+		return
+	}
+
+	offers, offerErr := offerScraper.ScrapeOffers(ctx, item)
+	if offerErr != nil {
+		if err == nil {
+			utils.Sugar.Infof("Could not scrape the offers for %s: %s", item.URL, offerErr)
+		}
+		return itemPrice, nil, err
+	}
+
+	best, offerErr := BestOffer(offers)
+	switch {
+	case offerErr != nil:
+		return itemPrice, nil, err
+	case err != nil:
+		return best, offers, nil
+	case best.Available || !itemPrice.Available:
+		if best.Price != itemPrice.Price {
+			// The published discount was for the other price
+			itemPrice.Discount = 0
+		}
+		if best.Seller != itemPrice.Seller {
+			// So was the shipping fee
+			itemPrice.ShippingFee = 0
+		}
+		itemPrice.Price = best.Price
+		itemPrice.Available = best.Available
+		itemPrice.Seller = best.Seller
+		setDiscount(&itemPrice)
+	}
+	return
+}
@@ -0,0 +1,321 @@
+package scraper
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/andybalholm/cascadia"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// configExtensions are the file types read from ConfigRoot. JSON is valid YAML.
+var configExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// FieldConfig describes where a field is found on a product page
+type FieldConfig struct {
+	Selector  string `yaml:"selector"`
+	Attribute string `yaml:"attribute"` // read the text of the element if empty
+}
+
+// PriceConfig describes where the price is found and how to clean it up
+type PriceConfig struct {
+	FieldConfig `yaml:",inline"`
+	Pattern     string `yaml:"pattern"` // optional regexp, the first group (or the whole match) is the price
+	Divisor     int64  `yaml:"divisor"` // optional, for stores that publish prices multiplied by a constant
+}
+
+// AvailabilityConfig describes how to tell if an item is in stock.
+// If neither InStock nor OutOfStock are set, the item is in stock when the element exists.
+// If no selector is set, the item is in stock when it has a price.
+type AvailabilityConfig struct {
+	FieldConfig `yaml:",inline"`
+	InStock     []string `yaml:"in_stock"`     // the value must contain one of these
+	OutOfStock  []string `yaml:"out_of_stock"` // the value must not contain any of these
+}
+
+// ScraperConfig is the declarative description of a store, loaded from a file in ConfigRoot
+type ScraperConfig struct {
+	Host         string             `yaml:"host"`
+	Aliases      []string           `yaml:"aliases"`
+	Currency     string             `yaml:"currency"`
+	Name         FieldConfig        `yaml:"name"`
+	Description  FieldConfig        `yaml:"description"`
+	Image        FieldConfig        `yaml:"image"`
+	Price        PriceConfig        `yaml:"price"`
+	Availability AvailabilityConfig `yaml:"availability"`
+
+	pattern *regexp.Regexp
+}
+
+// Validate checks that the config has every required field and that its selectors and pattern compile
+func (c *ScraperConfig) Validate() (err error) {
+	if c.Host == "" {
+		return errors.New("Missing host")
+	}
+
+	if c.Name.Selector == "" {
+		return errors.New("Missing name selector")
+	}
+
+	if c.Price.Selector == "" {
+		return errors.New("Missing price selector")
+	}
+
+	if c.Currency == "" {
+		c.Currency = DefaultCurrency
+	}
+
+	fields := map[string]FieldConfig{
+		"name":         c.Name,
+		"description":  c.Description,
+		"image":        c.Image,
+		"price":        c.Price.FieldConfig,
+		"availability": c.Availability.FieldConfig,
+	}
+
+	for field, config := range fields {
+		if config.Selector == "" {
+			continue
+		}
+		if _, err = cascadia.Compile(config.Selector); err != nil {
+			return errors.Wrapf(err, "Invalid %s selector %q", field, config.Selector)
+		}
+	}
+
+	if c.Price.Pattern != "" {
+		c.pattern, err = regexp.Compile(c.Price.Pattern)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid price pattern %q", c.Price.Pattern)
+		}
+	}
+
+	if c.Price.Divisor < 0 {
+		return errors.New(fmt.Sprintf("Invalid price divisor %d", c.Price.Divisor))
+	}
+
+	return
+}
+
+// LoadConfigs reads and validates every scraper config in root, creating scrapers that use fetcher.
+// A missing root folder is not an error, as configs are optional.
+func LoadConfigs(root string, fetcher Fetcher) (configScrapers []Scraper, err error) {
+	files, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		err = errors.Wrapf(err, "Cannot read scraper configs from %s", root)
+		return
+	}
+
+	hosts := make(map[string]string)
+	for _, file := range files {
+		if file.IsDir() || !configExtensions[strings.ToLower(filepath.Ext(file.Name()))] {
+			continue
+		}
+
+		filename := filepath.Join(root, file.Name())
+		var config ScraperConfig
+		config, err = loadConfig(filename)
+		if err != nil {
+			return
+		}
+
+		if other, ok := hosts[config.Host]; ok {
+			err = errors.New(fmt.Sprintf("Scraper config %s has the same host as %s", filename, other))
+			return
+		}
+		hosts[config.Host] = filename
+
+		configScrapers = append(configScrapers, ConfigScraper{Fetcher: fetcher, config: config})
+	}
+	return
+}
+
+// loadConfig reads and validates a single scraper config
+func loadConfig(filename string) (config ScraperConfig, err error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot read scraper config %s", filename)
+		return
+	}
+
+	err = yaml.UnmarshalStrict(data, &config)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot parse scraper config %s", filename)
+		return
+	}
+
+	err = config.Validate()
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid scraper config %s", filename)
+	}
+	return
+}
+
+// read returns the value of a field on the page
+func (f FieldConfig) read(doc *goquery.Document) (value string, exists bool) {
+	if f.Selector == "" {
+		return
+	}
+
+	sel := doc.Find(f.Selector).First()
+	if sel.Length() == 0 {
+		return
+	}
+
+	if f.Attribute == "" {
+		return strings.TrimSpace(sel.Text()), true
+	}
+	return sel.Attr(f.Attribute)
+}
+
+// price reads and cleans up the price on the page following the config's rules
+func (c ScraperConfig) price(doc *goquery.Document) (price int64) {
+	priceString, _ := c.Price.read(doc)
+
+	if c.pattern != nil {
+		match := c.pattern.FindStringSubmatch(priceString)
+		switch {
+		case match == nil:
+			return
+		case len(match) > 1:
+			priceString = match[1]
+		default:
+			priceString = match[0]
+		}
+	}
+
+	price = parsePrice(priceString, c.Currency)
+	if c.Price.Divisor > 0 {
+		price /= c.Price.Divisor
+	}
+	return
+}
+
+// available checks the availability rules of the config against the page
+func (c ScraperConfig) available(doc *goquery.Document, price int64) bool {
+	if c.Availability.Selector == "" {
+		return price > 0
+	}
+
+	value, exists := c.Availability.read(doc)
+	if !exists {
+		return false
+	}
+
+	value = strings.ToLower(value)
+	if len(c.Availability.InStock) > 0 {
+		for _, inStock := range c.Availability.InStock {
+			if strings.Contains(value, strings.ToLower(inStock)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, outOfStock := range c.Availability.OutOfStock {
+		if strings.Contains(value, strings.ToLower(outOfStock)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ConfigScraper implements Scraper for a store described by a ScraperConfig
+type ConfigScraper struct {
+	Fetcher Fetcher
+	config  ScraperConfig
+}
+
+// ScrapeInfo extracts the required information out of a page from the scraper config
+func (s ConfigScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	doc, err := GetDocument(s.Fetcher, path)
+
+	if err != nil {
+		return
+	}
+
+	// Name
+	name, exists := s.config.Name.read(doc)
+
+	if exists {
+		item.Name = html.UnescapeString(name)
+	}
+
+	// Description
+	description, exists := s.config.Description.read(doc)
+
+	if exists {
+		item.Description = html.UnescapeString(description)
+	}
+
+	// ImageURL
+	imageURL, exists := s.config.Image.read(doc)
+
+	if exists {
+		var urlParsed *url.URL
+		urlParsed, err = path.Parse(imageURL)
+		if err != nil {
+			return
+		}
+		item.ImageURL = "https://" + urlParsed.Host + urlParsed.Path
+	}
+
+	// URL
+	item.URL = "https://" + path.Host + path.Path
+
+	// Currency
+	item.Currency = s.config.Currency
+	return
+}
+
+// ScrapePrice returns the current price for an item
+func (s ConfigScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
+	sanitized, err := url.Parse(item.URL)
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL provided")
+		return
+	}
+
+	doc, err := GetDocument(s.Fetcher, sanitized)
+
+	if err != nil {
+		return
+	}
+
+	price := s.config.price(doc)
+
+	if price == int64(0) {
+		err = errors.New(fmt.Sprintf("Cannot parse price for %s with url %s", s.config.Host, item.URL))
+		return
+	}
+
+	itemPrice.Price = price
+	itemPrice.Available = s.config.available(doc, price)
+	itemPrice.Strategy = StrategyHTML
+	return
+}
+
+// GetHost returns the host name for the scraper
+func (s ConfigScraper) GetHost() (host string) {
+	host = s.config.Host
+	return
+}
+
+// Aliases returns the other hosts of the store listed in the config
+func (s ConfigScraper) Aliases() []string {
+	return s.config.Aliases
+}
@@ -1 +1,28 @@
 package scraper
+
+import (
+	"net/url"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+)
+
+// DMXScraper holds the Fetcher for the methods that implements Scraper for Điện Máy Xanh
+type DMXScraper struct {
+	Fetcher Fetcher
+}
+
+// ScrapeInfo reads the item from the Mobile World page of Điện Máy Xanh
+func (s DMXScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	return scrapeMobileWorldInfo(s.Fetcher, path)
+}
+
+// ScrapePrice returns the current price for an item
+func (s DMXScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
+	return scrapeMobileWorldPrice(s.Fetcher, item)
+}
+
+// GetHost returns the host name for the scraper
+func (s DMXScraper) GetHost() (host string) {
+	host = "www.dienmayxanh.com"
+	return
+}
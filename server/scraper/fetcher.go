@@ -0,0 +1,217 @@
+package scraper
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultUserAgent is sent by the fetcher if no User-Agent is configured.
+// Its product token, PricewatchBot, is the name stores can address in their robots.txt.
+const DefaultUserAgent = "PricewatchBot/1.0 (+https://github.com/UN0wen/pricewatch-vn)"
+
+// Fetcher downloads pages and API responses from the stores.
+// Every scraper receives the Fetcher it should use when it is created.
+type Fetcher interface {
+	Do(req *http.Request) (resp *http.Response, err error)
+}
+
+// StatusError is returned by the HTTPFetcher when a store responds with an error status
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+// Error describes the response
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("The external server responded with %s for %s", e.Status, e.URL)
+}
+
+// FetcherConfig configures an HTTPFetcher
+type FetcherConfig struct {
+	Timeout           time.Duration     // timeout of a single request, including reading the body
+	Retries           int               // number of retries after a network error, a 5xx or a 429
+	Backoff           time.Duration     // delay before the first retry, doubled for every retry
+	RequestsPerSecond float64           // per host rate limit, no limit if 0
+	Burst             int               // number of requests a host can receive at once
+	UserAgent         string            // DefaultUserAgent if empty
+	Headers           map[string]string // sent with every request
+	Proxy             string            // optional HTTP proxy URL
+}
+
+// HTTPFetcher is a Fetcher with timeouts, retries with exponential backoff
+// and a token bucket rate limit for every host
+type HTTPFetcher struct {
+	client  *http.Client
+	config  FetcherConfig
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewHTTPFetcher creates a HTTPFetcher from its config
+func NewHTTPFetcher(config FetcherConfig) (fetcher *HTTPFetcher, err error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.Proxy != "" {
+		var proxyURL *url.URL
+		proxyURL, err = url.Parse(config.Proxy)
+		if err != nil {
+			err = errors.Wrapf(err, "Invalid proxy URL %s", config.Proxy)
+			return
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if config.UserAgent == "" {
+		config.UserAgent = DefaultUserAgent
+	}
+
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+
+	fetcher = &HTTPFetcher{
+		client:  &http.Client{Timeout: config.Timeout, Transport: transport},
+		config:  config,
+		buckets: make(map[string]*tokenBucket),
+	}
+	return
+}
+
+// UserAgent returns the User-Agent sent with every request
+func (f *HTTPFetcher) UserAgent() string {
+	return f.config.UserAgent
+}
+
+// Do sends the request once the host's rate limit allows it, and retries it on temporary failures
+func (f *HTTPFetcher) Do(req *http.Request) (resp *http.Response, err error) {
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	for header, value := range f.config.Headers {
+		req.Header.Set(header, value)
+	}
+
+	// A store's Retry-After is followed up to the longest backoff, so it can't hold up an update for hours.
+	// The request's deadline still cancels the wait.
+	maxDelay := f.config.Backoff << uint(f.config.Retries)
+
+	delay := f.config.Backoff
+	for attempt := 0; ; attempt++ {
+		err = f.bucket(req.URL.Host).wait(req)
+		if err != nil {
+			return
+		}
+
+		resp, err = f.client.Do(req)
+
+		retry := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retry || attempt >= f.config.Retries {
+			break
+		}
+
+		wait := delay
+		if resp != nil {
+			// Stores that rate limit us say when to come back
+			if seconds, e := strconv.Atoi(resp.Header.Get("Retry-After")); e == nil && seconds > 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+			if wait > maxDelay {
+				wait = maxDelay
+			}
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			err = req.Context().Err()
+			return nil, err
+		}
+		delay *= 2
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err, "The external server can't be reached")
+		return
+	}
+
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		err = &StatusError{URL: req.URL.String(), StatusCode: resp.StatusCode, Status: resp.Status}
+		return nil, err
+	}
+	return
+}
+
+// bucket returns the rate limit of a host
+func (f *HTTPFetcher) bucket(host string) *tokenBucket {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	bucket, ok := f.buckets[host]
+	if !ok {
+		bucket = newTokenBucket(f.config.RequestsPerSecond, f.config.Burst)
+		f.buckets[host] = bucket
+	}
+	return bucket
+}
+
+// tokenBucket is a rate limiter refilled with rate tokens per second, holding up to burst tokens
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket. A rate of 0 disables the limit.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve takes a token and returns how long to wait before it can be used
+func (b *tokenBucket) reserve() time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait blocks until the request may be sent, or until it is cancelled
+func (b *tokenBucket) wait(req *http.Request) (err error) {
+	delay := b.reserve()
+	if delay == 0 {
+		return
+	}
+
+	select {
+	case <-time.After(delay):
+	case <-req.Context().Done():
+		err = req.Context().Err()
+	}
+	return
+}
@@ -0,0 +1,148 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+)
+
+// flakyServer answers with statuses in order, then with 200, and sets retryAfter on its error responses
+func flakyServer(t *testing.T, statuses []int, retryAfter string) (server *httptest.Server, requests *int32) {
+	requests = new(int32)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(requests, 1))
+		if n <= len(statuses) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return
+}
+
+func TestFetcherRetries(t *testing.T) {
+	cases := []struct {
+		name       string
+		statuses   []int
+		retryAfter string
+		status     int // status of the StatusError, 0 if the request succeeds
+		requests   int32
+	}{
+		{"success", nil, "", 0, 1},
+		{"server error then success", []int{500, 503}, "", 0, 3},
+		{"rate limited then success", []int{429}, "", 0, 2},
+		{"long Retry-After is capped", []int{429}, "3600", 0, 2},
+		{"retries exhausted", []int{502, 502, 502, 502}, "", 502, 4},
+		{"client error is not retried", []int{404}, "", 404, 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, requests := flakyServer(t, tc.statuses, tc.retryAfter)
+
+			fetcher, err := scraper.NewHTTPFetcher(scraper.FetcherConfig{Timeout: 5 * time.Second, Retries: 3, Backoff: 10 * time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := fetcher.Do(req)
+			var statusErr *scraper.StatusError
+			switch {
+			case tc.status == 0 && err != nil:
+				t.Fatalf("got error %s", err)
+			case tc.status == 0:
+				resp.Body.Close()
+			case !errors.As(err, &statusErr) || statusErr.StatusCode != tc.status:
+				t.Fatalf("got error %v, want status %d", err, tc.status)
+			}
+
+			if got := atomic.LoadInt32(requests); got != tc.requests {
+				t.Errorf("got %d requests, want %d", got, tc.requests)
+			}
+		})
+	}
+}
+
+func TestFetcherRetryCancelled(t *testing.T) {
+	server, _ := flakyServer(t, []int{503, 503}, "")
+
+	fetcher, err := scraper.NewHTTPFetcher(scraper.FetcherConfig{Timeout: 5 * time.Second, Retries: 3, Backoff: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err = fetcher.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the backoff ignored the deadline, waited %s", elapsed)
+	}
+}
+
+func TestFetcherRateLimit(t *testing.T) {
+	cases := []struct {
+		name    string
+		rate    float64
+		burst   int
+		minimum time.Duration // least time 5 requests can take
+		maximum time.Duration
+	}{
+		{"no limit", 0, 1, 0, 200 * time.Millisecond},
+		{"burst then rate", 20, 3, 90 * time.Millisecond, 500 * time.Millisecond},
+		{"one at a time", 20, 1, 190 * time.Millisecond, 600 * time.Millisecond},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, _ := flakyServer(t, nil, "")
+
+			fetcher, err := scraper.NewHTTPFetcher(scraper.FetcherConfig{Timeout: 5 * time.Second, RequestsPerSecond: tc.rate, Burst: tc.burst})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			for i := 0; i < 5; i++ {
+				req, err := http.NewRequest("GET", server.URL, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				resp, err := fetcher.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+
+			if elapsed := time.Since(start); elapsed < tc.minimum || elapsed > tc.maximum {
+				t.Errorf("5 requests took %s, want between %s and %s", elapsed, tc.minimum, tc.maximum)
+			}
+		})
+	}
+}
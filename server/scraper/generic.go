@@ -0,0 +1,71 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/pkg/errors"
+)
+
+// GenericScraper reads the structured data (JSON-LD, microdata, OpenGraph) of stores
+// that don't have a dedicated scraper. It is returned by Get for every unknown host.
+type GenericScraper struct {
+	Fetcher Fetcher
+}
+
+// ScrapeInfo extracts the required information out of a page from its structured data
+func (s GenericScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	return s.ScrapeInfoContext(context.Background(), path)
+}
+
+// ScrapeInfoContext is ScrapeInfo with a context that cancels the download of the page
+func (s GenericScraper) ScrapeInfoContext(ctx context.Context, path *url.URL) (item models.Item, err error) {
+	item, _, err = s.Detect(ctx, path)
+	return
+}
+
+// Detect reads an item from a page and returns the strategy that can be used to read its price.
+// It returns an error if the page has no structured price data. ctx cancels the download of the page.
+func (s GenericScraper) Detect(ctx context.Context, path *url.URL) (item models.Item, strategy string, err error) {
+	doc, err := GetDocumentContext(ctx, s.Fetcher, path)
+
+	if err != nil {
+		return
+	}
+
+	_, _, _, strategy = structuredPrice(doc)
+
+	if strategy == "" {
+		err = errors.New(fmt.Sprintf("Cannot find a price in the structured data of %s", path.String()))
+		return
+	}
+
+	item, err = parseSchemaInfo(doc, path)
+	return
+}
+
+// ScrapePrice returns the current price for an item
+func (s GenericScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
+	return s.ScrapePriceContext(context.Background(), item)
+}
+
+// ScrapePriceContext is ScrapePrice with a context that cancels the requests of the strategies
+func (s GenericScraper) ScrapePriceContext(ctx context.Context, item models.Item) (itemPrice models.ItemPrice, err error) {
+	return ScrapeChainContext(ctx, s.Fetcher, item, s.Strategies())
+}
+
+// Strategies returns the structured data readers, in the order Detect tries them
+func (s GenericScraper) Strategies() []Strategy {
+	return []Strategy{
+		DocumentStrategy(StrategyJSONLD, jsonLDPrice),
+		DocumentStrategy(StrategyMicrodata, microdataPrice),
+		DocumentStrategy(StrategyOpenGraph, openGraphPrice),
+	}
+}
+
+// GetHost returns the host name for the scraper, which is empty as it handles any host
+func (s GenericScraper) GetHost() (host string) {
+	return
+}
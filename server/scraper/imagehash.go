@@ -0,0 +1,83 @@
+package scraper
+
+import (
+	"context"
+	"image"
+	"math/bits"
+
+	// Decoders of the image formats the stores use for product images
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/pkg/errors"
+)
+
+// dHashWidth and dHashHeight are the size the image is reduced to before hashing.
+// Comparing the 9 columns two by two gives the 8 bits of every row.
+const (
+	dHashWidth  = 9
+	dHashHeight = 8
+)
+
+// ImageHash downloads an image and returns its DHash.
+// Only JPEG, PNG and GIF images can be hashed.
+func ImageHash(ctx context.Context, fetcher Fetcher, imageURL string) (hash uint64, err error) {
+	resp, err := fetch(ctx, fetcher, imageURL, "image/jpeg, image/png, image/gif")
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot decode the image %s", imageURL)
+		return
+	}
+	return DHash(img), nil
+}
+
+// DHash computes the difference hash of an image: the image is reduced to 9x8 gray pixels,
+// and every bit tells if a pixel is brighter than the pixel on its right.
+// Resized, recompressed or slightly retouched copies of an image have hashes a few bits apart.
+func DHash(img image.Image) (hash uint64) {
+	var gray [dHashHeight][dHashWidth]float64
+	var count [dHashHeight][dHashWidth]int
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := (y - bounds.Min.Y) * dHashHeight / bounds.Dy()
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			column := (x - bounds.Min.X) * dHashWidth / bounds.Dx()
+
+			// Luma of the pixel
+			r, g, b, _ := img.At(x, y).RGBA()
+			gray[row][column] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			count[row][column]++
+		}
+	}
+
+	// Every pixel of the reduced image is the average of the pixels it covers
+	for row := 0; row < dHashHeight; row++ {
+		for column := 0; column < dHashWidth; column++ {
+			if count[row][column] > 0 {
+				gray[row][column] /= float64(count[row][column])
+			}
+		}
+	}
+
+	for row := 0; row < dHashHeight; row++ {
+		for column := 0; column < dHashWidth-1; column++ {
+			hash <<= 1
+			if gray[row][column] > gray[row][column+1] {
+				hash |= 1
+			}
+		}
+	}
+	return
+}
+
+// HashDistance is the number of bits two image hashes differ by, 0 for identical images
+func HashDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
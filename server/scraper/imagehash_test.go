@@ -0,0 +1,86 @@
+package scraper_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+)
+
+// productImage draws a product photo: a dark bottle on a light background, at any size
+func productImage(width int, height int, mirrored bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := float64(x)/float64(width), float64(y)/float64(height)
+			if mirrored {
+				fx = 1 - fx
+			}
+
+			c := color.RGBA{R: uint8(230 - 60*fy), G: uint8(230 - 40*fx), B: 220, A: 255}
+			if fx > 0.2 && fx < 0.45 && fy > 0.15+0.2*fx {
+				c = color.RGBA{R: 40, G: uint8(60 + 100*fy), B: 90, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	original := scraper.DHash(productImage(600, 600, false))
+
+	// The same photo resized and recompressed by another store
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, productImage(250, 250, false), &jpeg.Options{Quality: 60}); err != nil {
+		t.Fatal(err)
+	}
+	recompressed, _, err := image.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if distance := scraper.HashDistance(original, scraper.DHash(recompressed)); distance > 6 {
+		t.Errorf("resized copy: got a distance of %d, want at most 6", distance)
+	}
+
+	if distance := scraper.HashDistance(original, scraper.DHash(productImage(600, 600, true))); distance < 16 {
+		t.Errorf("different image: got a distance of %d, want at least 16", distance)
+	}
+}
+
+func TestImageHash(t *testing.T) {
+	img := productImage(300, 300, false)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(server.Close)
+
+	fetcher, err := scraper.NewHTTPFetcher(scraper.FetcherConfig{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := scraper.ImageHash(context.Background(), fetcher, server.URL+"/image.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hash != scraper.DHash(img) {
+		t.Errorf("got hash %016x, want %016x", hash, scraper.DHash(img))
+	}
+}
@@ -1,27 +1,60 @@
 package scraper
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html"
 	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/UN0wen/pricewatch-vn/server/api/models"
 	"github.com/pkg/errors"
 )
 
-// LazadaScraper is an empty struct to hold methods that implements Scraper
-type LazadaScraper struct{}
+// lazadaPrice is the visible price element used when the structured and page data fail
+const lazadaPrice = ".pdp-price_type_normal"
+
+// lazadaProductID matches the item id in a Lazada URL, e.g. /products/dien-thoai-i123-s456.html
+var lazadaProductID = regexp.MustCompile(`-i(\d+)(?:-s\d+)?\.html$`)
+
+// lazadaSearchAPI is the catalog page, which returns the products matching a keyword as JSON with ajax=true
+const lazadaSearchAPI = "https://www.lazada.vn/catalog/?ajax=true&q=%s"
+
+// lazadaSearch is the part of Lazada's catalog JSON used by the scraper
+type lazadaSearch struct {
+	Mods struct {
+		ListItems []struct {
+			Name       string `json:"name"`
+			ProductURL string `json:"productUrl"` // without a scheme, e.g. //www.lazada.vn/products/...
+			Price      string `json:"price"`      // e.g. "7490000.00"
+			Image      string `json:"image"`
+		} `json:"listItems"`
+	} `json:"mods"`
+}
+
+// LazadaScraper holds the Fetcher for the methods that implements Scraper
+type LazadaScraper struct {
+	Fetcher Fetcher
+}
 
 // ScrapeInfo extracts the required information out of a page from the scraper config
 func (s LazadaScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	return s.ScrapeInfoContext(context.Background(), path)
+}
+
+// ScrapeInfoContext is ScrapeInfo with a context that cancels the download of the page
+func (s LazadaScraper) ScrapeInfoContext(ctx context.Context, path *url.URL) (item models.Item, err error) {
 	// sanitized, err := url.Parse(path)
 	// if err != nil {
 	// 	err = errors.Wrapf(err, "Invalid URL provided")
 	// 	return
 	// }
 
-	doc, err := GetDocument(path)
+	doc, err := GetDocumentContext(ctx, s.Fetcher, path)
 
 	if err != nil {
 		return
@@ -59,58 +92,319 @@ func (s LazadaScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
 	item.URL = "https://" + path.Host + path.Path
 
 	// Currency
-	item.Currency = "VND"
+	item.Currency = pageCurrency(doc)
 	return
 }
 
 // ScrapePrice returns the current price for an item
 func (s LazadaScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
-	var price int64
-	var available bool
+	return s.ScrapePriceContext(context.Background(), item)
+}
+
+// ScrapePriceContext is ScrapePrice with a context that cancels the requests of the strategies
+func (s LazadaScraper) ScrapePriceContext(ctx context.Context, item models.Item) (itemPrice models.ItemPrice, err error) {
+	return ScrapeChainContext(ctx, s.Fetcher, item, s.Strategies())
+}
+
+// Strategies returns the ways Lazada's price is read, starting with the JSON-LD offer
+func (s LazadaScraper) Strategies() []Strategy {
+	return []Strategy{
+		DocumentStrategy(StrategyJSONLD, jsonLDPrice),
+		DocumentStrategy(StrategyModuleData, lazadaModulePrice),
+		SelectorStrategy(lazadaPrice),
+	}
+}
+
+// lazadaModulePrice reads the price of the cheapest variant from the page data,
+// preferring variants in stock
+func lazadaModulePrice(doc *goquery.Document, defaultCurrency string) (itemPrice models.ItemPrice, currency string) {
+	pageData, err := parseLazadaPageData(doc)
+	if err != nil {
+		return
+	}
+
+	for _, info := range pageData.Data.Root.Fields.SkuInfos {
+		skuPrice := toMinor(info.Price.SalePrice.Value, defaultCurrency)
+		skuAvailable := info.Stock > 0
+		if skuPrice == 0 || (itemPrice.Available && !skuAvailable) {
+			continue
+		}
+
+		if itemPrice.Price == 0 || (skuAvailable && !itemPrice.Available) || skuPrice < itemPrice.Price {
+			itemPrice.Price = skuPrice
+			itemPrice.Available = skuAvailable
+			itemPrice.OriginalPrice = toMinor(info.Price.OriginalPrice.Value, defaultCurrency)
+		}
+	}
+	return
+}
+
+// Search returns the first products of Lazada's catalog for query
+func (s LazadaScraper) Search(ctx context.Context, query string) (results []SearchResult, err error) {
+	var search lazadaSearch
+	err = GetJSONContext(ctx, s.Fetcher, fmt.Sprintf(lazadaSearchAPI, url.QueryEscape(query)), &search)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot search Lazada for %s", query)
+		return
+	}
 
+	for _, product := range search.Mods.ListItems {
+		if product.ProductURL == "" {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Name:     html.UnescapeString(product.Name),
+			Price:    parsePrice(product.Price, DefaultCurrency),
+			URL:      "https:" + product.ProductURL,
+			ImageURL: product.Image,
+		})
+		if len(results) == SearchLimit {
+			break
+		}
+	}
+	return
+}
+
+// GetHost returns the host name for the scraper
+func (s LazadaScraper) GetHost() (host string) {
+	host = "www.lazada.vn"
+	return
+}
+
+// ProductID returns the store's stable id for the product at path
+func (s LazadaScraper) ProductID(path *url.URL) string {
+	if match := lazadaProductID.FindStringSubmatch(path.Path); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// lazadaModuleData matches the page data Lazada embeds in its product pages
+var lazadaModuleData = regexp.MustCompile(`(?s)__moduleData__\s*=\s*(\{.*?\});\s*(?:var |</script>|$)`)
+
+// lazadaSkuPrice is a price in Lazada's page data
+type lazadaSkuPrice struct {
+	SalePrice struct {
+		Value float64 `json:"value"`
+	} `json:"salePrice"`
+	OriginalPrice struct {
+		Value float64 `json:"value"`
+	} `json:"originalPrice"`
+}
+
+// lazadaSeller is a seller in Lazada's page data.
+// Price and Stock are only set for the other sellers, the page's seller sells at the variants' prices.
+type lazadaSeller struct {
+	Name      string         `json:"name"`
+	URL       string         `json:"url"`
+	IsLazMall bool           `json:"isLazMall"`
+	Price     lazadaSkuPrice `json:"price"`
+	Stock     int64          `json:"stock"`
+}
+
+// lazadaPageData is the part of Lazada's page data that describes the variants and sellers of an item
+type lazadaPageData struct {
+	Data struct {
+		Root struct {
+			Fields struct {
+				FlashSale *struct {
+					Title     string         `json:"title"`
+					Price     lazadaSkuPrice `json:"price"`
+					StartTime int64          `json:"startTime"` // in milliseconds
+					EndTime   int64          `json:"endTime"`
+				} `json:"flashSale"`
+				Seller       lazadaSeller   `json:"seller"`
+				OtherSellers []lazadaSeller `json:"otherSellers"`
+				SkuInfos     map[string]struct {
+					SkuID string         `json:"skuId"`
+					Stock int64          `json:"stock"`
+					Price lazadaSkuPrice `json:"price"`
+				} `json:"skuInfos"`
+				SkuBase struct {
+					Skus []struct {
+						SkuID    string `json:"skuId"`
+						PropPath string `json:"propPath"`
+					} `json:"skus"`
+					Properties []struct {
+						PID    string `json:"pid"`
+						Values []struct {
+							VID  string `json:"vid"`
+							Name string `json:"name"`
+						} `json:"values"`
+					} `json:"properties"`
+				} `json:"skuBase"`
+			} `json:"fields"`
+		} `json:"root"`
+	} `json:"data"`
+}
+
+// parseLazadaPageData reads the page data embedded in a Lazada product page.
+// pageData is empty if the page has none.
+func parseLazadaPageData(doc *goquery.Document) (pageData lazadaPageData, err error) {
+	doc.Find("script").EachWithBreak(func(i int, sel *goquery.Selection) bool {
+		match := lazadaModuleData.FindStringSubmatch(sel.Text())
+		if match == nil {
+			return true
+		}
+		err = json.Unmarshal([]byte(match[1]), &pageData)
+		return false
+	})
+	return
+}
+
+// ScrapeOffers returns the offer of the page's seller and of every other seller of the item.
+// LazMall sellers are the official stores.
+func (s LazadaScraper) ScrapeOffers(ctx context.Context, item models.Item) (offers []models.ItemOffer, err error) {
 	sanitized, err := url.Parse(item.URL)
 	if err != nil {
 		err = errors.Wrapf(err, "Invalid URL provided")
 		return
 	}
 
-	doc, err := GetDocument(sanitized)
-
-	jsonData := doc.Find("script[type=\"application/ld+json\"]").First().Text()
+	doc, err := GetDocumentContext(ctx, s.Fetcher, sanitized)
 
-	priceJSON := make(map[string]interface{})
+	if err != nil {
+		return
+	}
 
-	err = json.Unmarshal([]byte(jsonData), &priceJSON)
+	pageData, err := parseLazadaPageData(doc)
 
 	if err != nil {
-		err = errors.Wrapf(err, "Cannot parse json from Lazada from URL %s", item.URL)
+		err = errors.Wrapf(err, "Cannot parse sellers from Lazada from URL %s", item.URL)
 		return
 	}
 
-	if offers, ok := priceJSON["offers"]; ok {
-		offersMap := offers.(map[string]interface{})
-		if low, ok := offersMap["lowPrice"]; ok {
-			price = int64(low.(float64))
-		} else if high, ok := offersMap["highPrice"]; ok {
-			price = int64(high.(float64))
+	fields := pageData.Data.Root.Fields
+	if seller := fields.Seller; seller.Name != "" {
+		itemPrice, _ := lazadaModulePrice(doc, item.Currency)
+		if itemPrice.Price > 0 {
+			offers = append(offers, models.ItemOffer{
+				Seller:    seller.Name,
+				URL:       item.URL,
+				Price:     itemPrice.Price,
+				Available: itemPrice.Available,
+				Official:  seller.IsLazMall,
+			})
 		}
+	}
 
-		if avail, ok := offersMap["availability"]; ok {
-			available = avail.(string) == InStockHTTPS
+	for _, seller := range fields.OtherSellers {
+		price := toMinor(seller.Price.SalePrice.Value, item.Currency)
+		if seller.Name == "" || price == 0 {
+			continue
 		}
+
+		offers = append(offers, models.ItemOffer{
+			Seller:    seller.Name,
+			URL:       seller.URL,
+			Price:     price,
+			Available: seller.Stock > 0,
+			Official:  seller.IsLazMall,
+		})
+	}
+
+	if len(offers) == 0 {
+		err = errors.New(fmt.Sprintf("Cannot find any seller for Lazada url %s", item.URL))
+	}
+	return
+}
+
+// ScrapePromotions returns the item's flash sale, if it has one
+func (s LazadaScraper) ScrapePromotions(ctx context.Context, item models.Item) (promotions []models.ItemPromotion, err error) {
+	sanitized, err := url.Parse(item.URL)
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL provided")
+		return
+	}
+
+	doc, err := GetDocumentContext(ctx, s.Fetcher, sanitized)
+
+	if err != nil {
+		return
+	}
+
+	pageData, err := parseLazadaPageData(doc)
+
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot parse promotions from Lazada from URL %s", item.URL)
+		return
 	}
 
-	if price == int64(0) {
-		err = errors.New(fmt.Sprintf("Cannot parse price for Lazada with url %s", item.URL))
+	flashSale := pageData.Data.Root.Fields.FlashSale
+	if flashSale == nil || flashSale.Price.SalePrice.Value == 0 || flashSale.EndTime == 0 {
 		return
 	}
-	itemPrice.Price = price
-	itemPrice.Available = available
+
+	// The regular price is the one the variants sell at outside of the flash sale
+	regular, _ := lazadaModulePrice(doc, item.Currency)
+	promotions = append(promotions, models.ItemPromotion{
+		Label:        flashSale.Title,
+		Price:        toMinor(flashSale.Price.SalePrice.Value, item.Currency),
+		RegularPrice: regular.Price,
+		StartsAt:     time.Unix(0, flashSale.StartTime*int64(time.Millisecond)).UTC(),
+		EndsAt:       time.Unix(0, flashSale.EndTime*int64(time.Millisecond)).UTC(),
+	})
 	return
 }
 
-// GetHost returns the host name for the scraper
-func (s LazadaScraper) GetHost() (host string) {
-	host = "www.lazada.vn"
+// ScrapeVariants returns every variant of an item with its own price
+func (s LazadaScraper) ScrapeVariants(ctx context.Context, item models.Item) (variants []models.ItemVariant, prices []models.ItemPrice, err error) {
+	sanitized, err := url.Parse(item.URL)
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL provided")
+		return
+	}
+
+	doc, err := GetDocumentContext(ctx, s.Fetcher, sanitized)
+
+	if err != nil {
+		return
+	}
+
+	pageData, err := parseLazadaPageData(doc)
+
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot parse variants from Lazada from URL %s", item.URL)
+		return
+	}
+
+	fields := pageData.Data.Root.Fields
+
+	// Property values are referenced as pid:vid in each SKU's propPath
+	valueNames := make(map[string]string)
+	for _, property := range fields.SkuBase.Properties {
+		for _, value := range property.Values {
+			valueNames[property.PID+":"+value.VID] = value.Name
+		}
+	}
+
+	for _, sku := range fields.SkuBase.Skus {
+		info, ok := fields.SkuInfos[sku.SkuID]
+		if !ok || info.Price.SalePrice.Value == 0 {
+			continue
+		}
+
+		var names []string
+		for _, prop := range strings.Split(sku.PropPath, ";") {
+			if name, ok := valueNames[prop]; ok {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			names = append(names, sku.SkuID)
+		}
+
+		variants = append(variants, models.ItemVariant{SKU: sku.SkuID, Name: strings.Join(names, " / ")})
+		itemPrice := models.ItemPrice{
+			SKU:           sku.SkuID,
+			Price:         toMinor(info.Price.SalePrice.Value, item.Currency),
+			Available:     info.Stock > 0,
+			Strategy:      StrategyModuleData,
+			OriginalPrice: toMinor(info.Price.OriginalPrice.Value, item.Currency),
+		}
+		setDiscount(&itemPrice)
+		prices = append(prices, itemPrice)
+	}
 	return
 }
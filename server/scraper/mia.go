@@ -1 +1,31 @@
 package scraper
+
+import (
+	"net/url"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+)
+
+// miaPrice is the visible price element used when the page has no structured data
+const miaPrice = ".product-info-price .special-price .price, .product-info-price .price"
+
+// MiaScraper holds the Fetcher for the methods that implements Scraper for Mia.vn
+type MiaScraper struct {
+	Fetcher Fetcher
+}
+
+// ScrapeInfo reads the item from the schema.org data of the Mia.vn page
+func (s MiaScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	return scrapeSchemaInfo(s.Fetcher, path)
+}
+
+// ScrapePrice returns the current price for an item
+func (s MiaScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
+	return scrapeSchemaPrice(s.Fetcher, item, miaPrice)
+}
+
+// GetHost returns the host name for the scraper
+func (s MiaScraper) GetHost() (host string) {
+	host = "mia.vn"
+	return
+}
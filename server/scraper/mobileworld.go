@@ -0,0 +1,123 @@
+package scraper
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/pkg/errors"
+)
+
+// Selectors shared by every Mobile World (Thế Giới Di Động, Điện Máy Xanh) product page
+const (
+	mobileWorldPrice     = ".box-price-present, .area_price strong"
+	mobileWorldListPrice = ".box-price-old, .area_price .hisprice"
+	mobileWorldStatus    = ".productstatus, .box-status"
+)
+
+// mobileWorldOutOfStock are the status messages shown instead of the buy button
+var mobileWorldOutOfStock = []string{
+	"hết hàng",
+	"ngừng kinh doanh",
+	"sắp về hàng",
+}
+
+// mobileWorldProduct is what the shared Mobile World parser reads from a product page
+type mobileWorldProduct struct {
+	Name      string
+	Price     int64
+	ListPrice int64 // strikethrough price before discount, 0 if there is no discount
+	Available bool
+}
+
+// parseMobileWorld parses a Thế Giới Di Động or Điện Máy Xanh product page
+func parseMobileWorld(doc *goquery.Document) (product mobileWorldProduct) {
+	product.Name = strings.TrimSpace(doc.Find("h1").First().Text())
+	// The Mobile World sites only sell in đồng
+	product.Price = parsePrice(doc.Find(mobileWorldPrice).First().Text(), DefaultCurrency)
+	product.ListPrice = parsePrice(doc.Find(mobileWorldListPrice).First().Text(), DefaultCurrency)
+
+	status := strings.ToLower(doc.Find(mobileWorldStatus).First().Text())
+	product.Available = product.Price > 0
+	for _, message := range mobileWorldOutOfStock {
+		if strings.Contains(status, message) {
+			product.Available = false
+		}
+	}
+	return
+}
+
+// scrapeMobileWorldInfo implements ScrapeInfo for the Mobile World sites
+func scrapeMobileWorldInfo(fetcher Fetcher, path *url.URL) (item models.Item, err error) {
+	doc, err := GetDocument(fetcher, path)
+
+	if err != nil {
+		return
+	}
+
+	// Name
+	item.Name = parseMobileWorld(doc).Name
+	if item.Name == "" {
+		name, _ := doc.Find("meta[property=\"og:title\"]").Attr("content")
+		item.Name = html.UnescapeString(name)
+	}
+
+	// Description
+	description, exists := doc.Find("meta[name=\"description\"]").Attr("content")
+
+	if exists {
+		description = html.UnescapeString(description)
+		item.Description = description
+	}
+
+	// ImageURL
+	imageURL, exists := doc.Find("meta[property=\"og:image\"]").Attr("content")
+
+	if exists {
+		var urlParsed *url.URL
+		urlParsed, err = url.Parse(imageURL)
+		if err != nil {
+			return
+		}
+		item.ImageURL = "https://" + urlParsed.Host + urlParsed.Path
+	}
+
+	// URL
+	item.URL = "https://" + path.Host + path.Path
+
+	// Currency
+	item.Currency = pageCurrency(doc)
+	return
+}
+
+// scrapeMobileWorldPrice implements ScrapePrice for the Mobile World sites
+func scrapeMobileWorldPrice(fetcher Fetcher, item models.Item) (itemPrice models.ItemPrice, err error) {
+	sanitized, err := url.Parse(item.URL)
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL provided")
+		return
+	}
+
+	doc, err := GetDocument(fetcher, sanitized)
+
+	if err != nil {
+		return
+	}
+
+	product := parseMobileWorld(doc)
+
+	if product.Price == int64(0) {
+		err = errors.New(fmt.Sprintf("Cannot parse price for %s with url %s", sanitized.Host, item.URL))
+		return
+	}
+
+	itemPrice.Price = product.Price
+	itemPrice.Available = product.Available
+	itemPrice.Strategy = StrategyHTML
+	itemPrice.OriginalPrice = product.ListPrice
+	setDiscount(&itemPrice)
+	return
+}
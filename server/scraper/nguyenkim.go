@@ -1 +1,31 @@
 package scraper
+
+import (
+	"net/url"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+)
+
+// nguyenKimPrice is the visible price element used when the page has no structured data
+const nguyenKimPrice = ".product_info_price_value-final, .nk-price-final"
+
+// NguyenKimScraper holds the Fetcher for the methods that implements Scraper for Nguyễn Kim
+type NguyenKimScraper struct {
+	Fetcher Fetcher
+}
+
+// ScrapeInfo reads the item from the schema.org data of the Nguyễn Kim page
+func (s NguyenKimScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	return scrapeSchemaInfo(s.Fetcher, path)
+}
+
+// ScrapePrice returns the current price for an item
+func (s NguyenKimScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
+	return scrapeSchemaPrice(s.Fetcher, item, nguyenKimPrice)
+}
+
+// GetHost returns the host name for the scraper
+func (s NguyenKimScraper) GetHost() (host string) {
+	host = "www.nguyenkim.com"
+	return
+}
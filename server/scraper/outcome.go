@@ -0,0 +1,35 @@
+package scraper
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/pkg/errors"
+)
+
+// Outcome classifies the error returned by a scraper into one of the scrape outcomes of the models
+func Outcome(err error) string {
+	if err == nil {
+		return models.OutcomeSuccess
+	}
+
+	cause := errors.Cause(err)
+	if _, ok := cause.(*RobotsError); ok {
+		return models.OutcomeDisallowed
+	}
+
+	if statusErr, ok := cause.(*StatusError); ok {
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+			return models.OutcomeBlocked
+		}
+		return models.OutcomeNetwork
+	}
+
+	if _, ok := cause.(net.Error); ok || cause == context.DeadlineExceeded || cause == context.Canceled {
+		return models.OutcomeNetwork
+	}
+	return models.OutcomeParse
+}
@@ -0,0 +1,285 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RobotsError is returned by the RobotsFetcher for URLs the store's robots.txt disallows
+type RobotsError struct {
+	URL   string
+	Agent string
+}
+
+// Error describes the refused URL
+func (e *RobotsError) Error() string {
+	return fmt.Sprintf("The robots.txt of the store disallows %s for %s", e.URL, e.Agent)
+}
+
+// robotsRule is a single Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	pattern string
+	match   *regexp.Regexp
+}
+
+// newRobotsRule compiles the pattern of a rule, where * matches any characters and a final $ anchors the end of the path
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	expr := strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(expr, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	expr = "^" + strings.Join(parts, ".*")
+	if strings.HasSuffix(pattern, "$") {
+		expr += "$"
+	}
+	return robotsRule{allow: allow, pattern: pattern, match: regexp.MustCompile(expr)}
+}
+
+// RobotsRules are the rules of a robots.txt that apply to one user agent
+type RobotsRules struct {
+	rules      []robotsRule
+	CrawlDelay time.Duration // 0 if the robots.txt doesn't set one
+}
+
+// ParseRobots reads the rules of a robots.txt that apply to agent, the product token of the bot (e.g. "PricewatchBot").
+// The group naming the agent is used if there is one, the "*" group otherwise.
+func ParseRobots(body io.Reader, agent string) (rules RobotsRules, err error) {
+	agent = strings.ToLower(agent)
+
+	var named, wildcard *RobotsRules
+	var current []*RobotsRules // the groups the lines belong to
+	inAgents := false          // consecutive User-agent lines share a group
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+
+		if key == "user-agent" {
+			if !inAgents {
+				current = nil
+			}
+			inAgents = true
+
+			name := strings.ToLower(value)
+			switch {
+			case name == "*":
+				if wildcard == nil {
+					wildcard = &RobotsRules{}
+				}
+				current = append(current, wildcard)
+			case name == agent:
+				if named == nil {
+					named = &RobotsRules{}
+				}
+				current = append(current, named)
+			}
+			continue
+		}
+		inAgents = false
+
+		for _, group := range current {
+			switch key {
+			case "allow", "disallow":
+				// An empty Disallow allows everything
+				if value != "" {
+					group.rules = append(group.rules, newRobotsRule(key == "allow", value))
+				}
+			case "crawl-delay":
+				if seconds, e := strconv.ParseFloat(value, 64); e == nil && seconds > 0 {
+					group.CrawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		err = errors.Wrapf(err, "Cannot read robots.txt")
+		return
+	}
+
+	switch {
+	case named != nil:
+		rules = *named
+	case wildcard != nil:
+		rules = *wildcard
+	}
+	return
+}
+
+// Allowed checks if the rules allow a path, with its query.
+// The longest matching rule wins, and Allow wins a tie.
+func (r RobotsRules) Allowed(path string) bool {
+	allowed := true
+	longest := -1
+	for _, rule := range r.rules {
+		if !rule.match.MatchString(path) {
+			continue
+		}
+
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			allowed = rule.allow
+			longest = len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// robotsFailureTTL is how long a robots.txt that can't be downloaded is not asked for again.
+// Its host's requests fail with the download error in the meantime.
+const robotsFailureTTL = 5 * time.Minute
+
+// robotsHost is the cached robots.txt of a host, with its crawl delay schedule
+type robotsHost struct {
+	rules     RobotsRules
+	fetchedAt time.Time
+	schedule  *tokenBucket // nil without a crawl delay
+	err       error        // the download error, if the robots.txt couldn't be downloaded
+}
+
+// expired checks if the robots.txt of the host should be downloaded again.
+// Failed downloads are retried after robotsFailureTTL, or ttl if it is shorter.
+func (h *robotsHost) expired(ttl time.Duration) bool {
+	if h.err != nil && robotsFailureTTL < ttl {
+		ttl = robotsFailureTTL
+	}
+	return time.Since(h.fetchedAt) >= ttl
+}
+
+// RobotsFetcher is a Fetcher that follows the robots.txt of every store.
+// It refuses disallowed URLs with a RobotsError and spaces out the requests to a host by its Crawl-delay.
+// The robots.txt of a host is downloaded through the wrapped Fetcher and cached for ttl.
+type RobotsFetcher struct {
+	fetcher Fetcher
+	agent   string
+	ttl     time.Duration
+	mutex   sync.Mutex
+	hosts   map[string]*robotsHost
+}
+
+// NewRobotsFetcher wraps fetcher so that it follows the rules of the stores for userAgent
+func NewRobotsFetcher(fetcher Fetcher, userAgent string, ttl time.Duration) *RobotsFetcher {
+	return &RobotsFetcher{fetcher: fetcher, agent: RobotsAgent(userAgent), ttl: ttl, hosts: make(map[string]*robotsHost)}
+}
+
+// RobotsAgent returns the product token of a User-Agent, which robots.txt groups are matched against,
+// e.g. "PricewatchBot" for "PricewatchBot/1.0 (+https://...)"
+func RobotsAgent(userAgent string) string {
+	token := strings.Fields(userAgent + " ")[0]
+	if i := strings.Index(token, "/"); i >= 0 {
+		token = token[:i]
+	}
+	return token
+}
+
+// Do sends the request if the store's robots.txt allows it, once the host's crawl delay has passed
+func (f *RobotsFetcher) Do(req *http.Request) (resp *http.Response, err error) {
+	host, err := f.host(req.Context(), req.URL)
+	if err != nil {
+		return
+	}
+
+	path := req.URL.EscapedPath()
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+
+	if !host.rules.Allowed(path) {
+		err = &RobotsError{URL: req.URL.String(), Agent: f.agent}
+		return
+	}
+
+	if host.schedule != nil {
+		err = host.schedule.wait(req)
+		if err != nil {
+			return
+		}
+	}
+	return f.fetcher.Do(req)
+}
+
+// host returns the cached robots.txt of the URL's host, downloading it if it is missing or expired.
+// A failed download is cached too, so a store that is down isn't asked for its robots.txt before every request.
+func (f *RobotsFetcher) host(ctx context.Context, u *url.URL) (host *robotsHost, err error) {
+	f.mutex.Lock()
+	host, ok := f.hosts[u.Host]
+	f.mutex.Unlock()
+
+	if ok && !host.expired(f.ttl) {
+		err = host.err
+		return
+	}
+
+	rules, err := f.fetchRules(ctx, u)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err != nil {
+		// A cancelled update says nothing about the store
+		if ctx.Err() != nil {
+			return
+		}
+
+		failed := &robotsHost{fetchedAt: time.Now(), err: err}
+		if ok {
+			failed.rules, failed.schedule = host.rules, host.schedule
+		}
+		f.hosts[u.Host] = failed
+		return
+	}
+
+	// Keep the schedule of the previous robots.txt, so a refresh doesn't reset the crawl delay
+	if ok && host.rules.CrawlDelay == rules.CrawlDelay {
+		host = &robotsHost{rules: rules, fetchedAt: time.Now(), schedule: host.schedule}
+	} else {
+		host = &robotsHost{rules: rules, fetchedAt: time.Now()}
+		if rules.CrawlDelay > 0 {
+			host.schedule = newTokenBucket(1/rules.CrawlDelay.Seconds(), 1)
+		}
+	}
+	f.hosts[u.Host] = host
+	return
+}
+
+// fetchRules downloads and parses the robots.txt of the URL's host.
+// A missing robots.txt allows everything. If it can't be downloaded, nothing is allowed and the error is returned.
+func (f *RobotsFetcher) fetchRules(ctx context.Context, u *url.URL) (rules RobotsRules, err error) {
+	robotsURL := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	resp, err := fetch(ctx, f.fetcher, robotsURL.String(), "text/plain")
+
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 {
+			return rules, nil
+		}
+		err = errors.Wrapf(err, "Cannot download the robots.txt of %s", u.Host)
+		return
+	}
+
+	defer resp.Body.Close()
+	return ParseRobots(resp.Body, f.agent)
+}
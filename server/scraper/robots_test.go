@@ -0,0 +1,128 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+	"github.com/UN0wen/pricewatch-vn/server/scraper/scrapertest"
+)
+
+const robots = `User-agent: *
+Disallow: /search
+Disallow: /*?sort=
+
+User-agent: PricewatchBot
+Disallow: /account/
+Allow: /account/orders
+Disallow: /*.pdf$
+Crawl-delay: 2.5
+`
+
+func TestParseRobots(t *testing.T) {
+	cases := []struct {
+		agent   string
+		path    string
+		allowed bool
+	}{
+		{"PricewatchBot", "/products/abc", true},
+		{"PricewatchBot", "/search", true}, // the named group replaces the * group
+		{"PricewatchBot", "/account/settings", false},
+		{"PricewatchBot", "/account/orders", true}, // the longest rule wins
+		{"PricewatchBot", "/manual.pdf", false},
+		{"PricewatchBot", "/manual.pdf?page=2", true},
+		{"OtherBot", "/search", false},
+		{"OtherBot", "/products?sort=price", false},
+		{"OtherBot", "/account/settings", true},
+	}
+
+	for _, tc := range cases {
+		rules, err := scraper.ParseRobots(strings.NewReader(robots), tc.agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := rules.Allowed(tc.path); got != tc.allowed {
+			t.Errorf("%s %s: got allowed %t, want %t", tc.agent, tc.path, got, tc.allowed)
+		}
+	}
+
+	rules, _ := scraper.ParseRobots(strings.NewReader(robots), "PricewatchBot")
+	if rules.CrawlDelay != 2500*time.Millisecond {
+		t.Errorf("got crawl delay %s, want 2.5s", rules.CrawlDelay)
+	}
+}
+
+func TestRobotsFetcher(t *testing.T) {
+	server := scrapertest.NewServer(fixtureRoot)
+	t.Cleanup(server.Close)
+
+	fetcher := scraper.NewRobotsFetcher(server.Fetcher(), scraper.DefaultUserAgent, time.Hour)
+
+	get := func(rawURL string) error {
+		req, err := http.NewRequestWithContext(context.Background(), "GET", rawURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := fetcher.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get("https://www.example-store.vn/products/binh-giu-nhiet-500ml"); err != nil {
+		t.Errorf("allowed page: %s", err)
+	}
+
+	err := get("https://www.example-store.vn/cart")
+	var robotsErr *scraper.RobotsError
+	if !errors.As(err, &robotsErr) {
+		t.Fatalf("disallowed page: got %v, want a RobotsError", err)
+	}
+
+	if outcome := scraper.Outcome(err); outcome != models.OutcomeDisallowed {
+		t.Errorf("got outcome %s, want %s", outcome, models.OutcomeDisallowed)
+	}
+
+	// Stores without a robots.txt allow everything
+	if err := get("https://tiki.vn/sach-nha-gia-kim-p123456.html"); err != nil {
+		t.Errorf("store without robots.txt: %s", err)
+	}
+}
+
+// TestRobotsFetcherFailure checks that a robots.txt that can't be downloaded refuses the host's requests
+// without being downloaded again for each of them
+func TestRobotsFetcherFailure(t *testing.T) {
+	server, requests := flakyServer(t, []int{503}, "")
+
+	httpFetcher, err := scraper.NewHTTPFetcher(scraper.FetcherConfig{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher := scraper.NewRobotsFetcher(httpFetcher, scraper.DefaultUserAgent, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequestWithContext(context.Background(), "GET", server.URL+"/products/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := fetcher.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("request %d: got no error, want the robots.txt download error", i)
+		}
+	}
+
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("got %d requests, want only the robots.txt", got)
+	}
+}
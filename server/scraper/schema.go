@@ -0,0 +1,490 @@
+package scraper
+
+import (
+	"encoding/json"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+)
+
+// ldPrice is a schema.org price, which stores publish either as a number or as a string.
+// It is kept as text until the offer's currency is known.
+type ldPrice string
+
+// UnmarshalJSON accepts both numeric and string prices
+func (p *ldPrice) UnmarshalJSON(data []byte) (err error) {
+	var number float64
+	if err = json.Unmarshal(data, &number); err == nil {
+		*p = ldPrice(strconv.FormatFloat(number, 'f', -1, 64))
+		return
+	}
+
+	var text string
+	if err = json.Unmarshal(data, &text); err != nil {
+		return
+	}
+	*p = ldPrice(text)
+	return nil
+}
+
+// amount returns the price in the minor unit of currency
+func (p ldPrice) amount(currency string) int64 {
+	return parsePrice(string(p), currency)
+}
+
+// ldNumber is a schema.org number, which stores publish either as a number or as a string
+type ldNumber float64
+
+// UnmarshalJSON accepts both numbers and strings, with a decimal point or comma
+func (n *ldNumber) UnmarshalJSON(data []byte) (err error) {
+	var number float64
+	if err = json.Unmarshal(data, &number); err == nil {
+		*n = ldNumber(number)
+		return
+	}
+
+	var text string
+	if err = json.Unmarshal(data, &text); err != nil {
+		return
+	}
+	number, _ = strconv.ParseFloat(strings.Replace(strings.TrimSpace(text), ",", ".", 1), 64)
+	*n = ldNumber(number)
+	return nil
+}
+
+// ldOffer is a schema.org Offer or AggregateOffer
+type ldOffer struct {
+	Type               string          `json:"@type"`
+	Price              ldPrice         `json:"price"`
+	LowPrice           ldPrice         `json:"lowPrice"`
+	HighPrice          ldPrice         `json:"highPrice"`
+	PriceCurrency      string          `json:"priceCurrency"`
+	Availability       string          `json:"availability"`
+	URL                string          `json:"url"`
+	Seller             json.RawMessage `json:"seller"`             // an Organization or a name
+	PriceSpecification json.RawMessage `json:"priceSpecification"` // one or a list of UnitPriceSpecification
+	ShippingDetails    json.RawMessage `json:"shippingDetails"`    // one or a list of OfferShippingDetails
+}
+
+// ldPriceSpecification is a schema.org UnitPriceSpecification
+type ldPriceSpecification struct {
+	Price     ldPrice `json:"price"`
+	PriceType string  `json:"priceType"`
+}
+
+// ldShippingDetails is a schema.org OfferShippingDetails
+type ldShippingDetails struct {
+	ShippingRate struct {
+		Value ldPrice `json:"value"`
+	} `json:"shippingRate"`
+}
+
+// ldRating is a schema.org AggregateRating
+type ldRating struct {
+	RatingValue ldNumber `json:"ratingValue"`
+	ReviewCount ldNumber `json:"reviewCount"`
+	RatingCount ldNumber `json:"ratingCount"`
+}
+
+// ldProduct is a schema.org Product read from a page's JSON-LD
+type ldProduct struct {
+	Type            interface{}     `json:"@type"`
+	Name            string          `json:"name"`
+	Description     string          `json:"description"`
+	Image           interface{}     `json:"image"`
+	GTIN13          string          `json:"gtin13"`
+	ISBN            string          `json:"isbn"`
+	MPN             string          `json:"mpn"`
+	Offers          json.RawMessage `json:"offers"`
+	AggregateRating *ldRating       `json:"aggregateRating"`
+}
+
+// ldNode is used to walk the top level of a JSON-LD script, which may be a graph
+type ldNode struct {
+	ldProduct
+	Graph []json.RawMessage `json:"@graph"`
+}
+
+// findLDProduct returns the first schema.org Product in the JSON-LD scripts of doc
+func findLDProduct(doc *goquery.Document) (product ldProduct, found bool) {
+	doc.Find("script[type=\"application/ld+json\"]").EachWithBreak(func(i int, sel *goquery.Selection) bool {
+		product, found = decodeLDProduct([]byte(sel.Text()))
+		return !found
+	})
+	return
+}
+
+// decodeLDProduct looks for a Product in a JSON-LD object, array or @graph
+func decodeLDProduct(data []byte) (product ldProduct, found bool) {
+	var nodes []json.RawMessage
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		nodes = []json.RawMessage{data}
+	}
+
+	for _, raw := range nodes {
+		var node ldNode
+		if err := json.Unmarshal(raw, &node); err != nil {
+			continue
+		}
+
+		if hasLDType(node.Type, "Product") {
+			return node.ldProduct, true
+		}
+
+		for _, child := range node.Graph {
+			if product, found = decodeLDProduct(child); found {
+				return
+			}
+		}
+	}
+	return
+}
+
+// hasLDType checks an @type value, which can be a string or a list of strings
+func hasLDType(value interface{}, ldType string) bool {
+	switch t := value.(type) {
+	case string:
+		return t == ldType
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s == ldType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// offers returns the product's offers, whether they were published as one object or a list
+func (p ldProduct) offers() (offers []ldOffer) {
+	if len(p.Offers) == 0 {
+		return
+	}
+
+	if err := json.Unmarshal(p.Offers, &offers); err == nil {
+		return
+	}
+
+	var offer ldOffer
+	if err := json.Unmarshal(p.Offers, &offer); err == nil {
+		offers = []ldOffer{offer}
+	}
+	return
+}
+
+// imageURL returns the first image of the product
+func (p ldProduct) imageURL() string {
+	switch image := p.Image.(type) {
+	case string:
+		return image
+	case []interface{}:
+		for _, v := range image {
+			if s, ok := v.(string); ok {
+				return s
+			}
+		}
+	case map[string]interface{}:
+		if s, ok := image["url"].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// currency returns the currency of the offer's prices, or defaultCurrency if the offer doesn't say
+func (o ldOffer) currency(defaultCurrency string) string {
+	if o.PriceCurrency != "" {
+		return strings.ToUpper(o.PriceCurrency)
+	}
+	return defaultCurrency
+}
+
+// lowest returns the offer's price in the minor unit of currency, preferring the lowest price of an AggregateOffer
+func (o ldOffer) lowest(currency string) int64 {
+	for _, price := range []ldPrice{o.Price, o.LowPrice, o.HighPrice} {
+		if amount := price.amount(currency); amount > 0 {
+			return amount
+		}
+	}
+	return 0
+}
+
+// ldList decodes a JSON-LD value that can be a single object or a list of objects into v, a pointer to a slice
+func ldList(data json.RawMessage, v interface{}) {
+	if len(data) == 0 || json.Unmarshal(data, v) == nil {
+		return
+	}
+	json.Unmarshal(append(append([]byte("["), data...), ']'), v)
+}
+
+// listPrice returns the strikethrough price of the offer in the minor unit of currency, or 0 if there is none
+func (o ldOffer) listPrice(currency string) int64 {
+	var specifications []ldPriceSpecification
+	ldList(o.PriceSpecification, &specifications)
+
+	for _, specification := range specifications {
+		priceType := strings.ToLower(specification.PriceType)
+		if strings.HasSuffix(priceType, "listprice") || strings.HasSuffix(priceType, "strikethroughprice") {
+			return specification.Price.amount(currency)
+		}
+	}
+	return 0
+}
+
+// sellerName returns the name of the offer's seller
+func (o ldOffer) sellerName() string {
+	if len(o.Seller) == 0 {
+		return ""
+	}
+
+	var seller struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(o.Seller, &seller); err == nil {
+		return strings.TrimSpace(html.UnescapeString(seller.Name))
+	}
+
+	var name string
+	json.Unmarshal(o.Seller, &name)
+	return strings.TrimSpace(html.UnescapeString(name))
+}
+
+// shippingFee returns the cheapest shipping rate of the offer in the minor unit of currency, or 0 if it doesn't have one
+func (o ldOffer) shippingFee(currency string) (fee int64) {
+	var details []ldShippingDetails
+	ldList(o.ShippingDetails, &details)
+
+	for i, detail := range details {
+		if rate := detail.ShippingRate.Value.amount(currency); i == 0 || rate < fee {
+			fee = rate
+		}
+	}
+	return
+}
+
+// Strategies used to read the price from a page's structured data, in the order they are tried
+const (
+	StrategyJSONLD    = "json-ld"
+	StrategyMicrodata = "microdata"
+	StrategyOpenGraph = "opengraph"
+)
+
+// isInStock checks a schema.org or OpenGraph availability value,
+// e.g. https://schema.org/InStock, InStock or "in stock"
+func isInStock(availability string) bool {
+	availability = strings.TrimPrefix(availability, "https://schema.org/")
+	availability = strings.TrimPrefix(availability, "http://schema.org/")
+	availability = strings.ReplaceAll(availability, " ", "")
+	return strings.EqualFold(availability, "InStock")
+}
+
+// DocumentReader reads the price and the details of its offer from a product page,
+// in the minor unit of the page's currency, or of defaultCurrency if the page doesn't say.
+// currency is empty if the page doesn't say.
+type DocumentReader func(doc *goquery.Document, defaultCurrency string) (itemPrice models.ItemPrice, currency string)
+
+// structuredPrice reads the price, availability and currency from the structured data of a page,
+// trying JSON-LD, microdata and then OpenGraph. strategy is empty if none of them has a price.
+func structuredPrice(doc *goquery.Document) (price int64, available bool, currency string, strategy string) {
+	readers := []struct {
+		strategy string
+		read     DocumentReader
+	}{
+		{StrategyJSONLD, jsonLDPrice},
+		{StrategyMicrodata, microdataPrice},
+		{StrategyOpenGraph, openGraphPrice},
+	}
+
+	for _, reader := range readers {
+		itemPrice, currency := reader.read(doc, DefaultCurrency)
+		if itemPrice.Price > 0 {
+			return itemPrice.Price, itemPrice.Available, currency, reader.strategy
+		}
+	}
+	return 0, false, "", ""
+}
+
+// pageCurrency returns the currency of the price in the structured data of a page,
+// or DefaultCurrency if the page doesn't say
+func pageCurrency(doc *goquery.Document) string {
+	if _, _, currency, _ := structuredPrice(doc); currency != "" {
+		return strings.ToUpper(currency)
+	}
+	return DefaultCurrency
+}
+
+// jsonLDPrice reads the first offer with a price in the page's JSON-LD Product,
+// with the product's rating
+func jsonLDPrice(doc *goquery.Document, defaultCurrency string) (itemPrice models.ItemPrice, currency string) {
+	product, ok := findLDProduct(doc)
+	if !ok {
+		return
+	}
+
+	for _, offer := range product.offers() {
+		offerCurrency := offer.currency(defaultCurrency)
+		if price := offer.lowest(offerCurrency); price > 0 {
+			itemPrice.Price = price
+			itemPrice.Available = isInStock(offer.Availability)
+			itemPrice.OriginalPrice = offer.listPrice(offerCurrency)
+			itemPrice.Seller = offer.sellerName()
+			itemPrice.ShippingFee = offer.shippingFee(offerCurrency)
+			currency = offer.PriceCurrency
+			break
+		}
+	}
+
+	if itemPrice.Price > 0 && product.AggregateRating != nil {
+		itemPrice.Rating = float64(product.AggregateRating.RatingValue)
+		itemPrice.ReviewCount = int64(product.AggregateRating.ReviewCount)
+		if itemPrice.ReviewCount == 0 {
+			itemPrice.ReviewCount = int64(product.AggregateRating.RatingCount)
+		}
+	}
+	return
+}
+
+// microdataPrice reads the price from the page's schema.org microdata
+func microdataPrice(doc *goquery.Document, defaultCurrency string) (itemPrice models.ItemPrice, currency string) {
+	currency, _ = doc.Find("[itemprop=\"priceCurrency\"]").First().Attr("content")
+	priceCurrency := defaultCurrency
+	if currency != "" {
+		priceCurrency = currency
+	}
+
+	itemPrice.Price = parsePrice(microdataValue(doc, "price"), priceCurrency)
+	if itemPrice.Price == 0 {
+		return
+	}
+
+	availability := doc.Find("[itemprop=\"availability\"]").First()
+	availableString, exists := availability.Attr("href")
+	if !exists {
+		availableString, _ = availability.Attr("content")
+	}
+	itemPrice.Available = isInStock(availableString)
+
+	// Rating
+	itemPrice.Rating, _ = strconv.ParseFloat(strings.Replace(microdataValue(doc, "ratingValue"), ",", ".", 1), 64)
+	itemPrice.ReviewCount = parseCount(microdataValue(doc, "reviewCount"))
+	if itemPrice.ReviewCount == 0 {
+		itemPrice.ReviewCount = parseCount(microdataValue(doc, "ratingCount"))
+	}
+
+	// Seller
+	itemPrice.Seller = strings.TrimSpace(microdataValue(doc, "seller"))
+	return
+}
+
+// microdataValue returns the content or the text of the first element with the itemprop
+func microdataValue(doc *goquery.Document, itemprop string) string {
+	sel := doc.Find("[itemprop=\"" + itemprop + "\"]").First()
+	if value, exists := sel.Attr("content"); exists {
+		return value
+	}
+	return sel.Text()
+}
+
+// openGraphPrice reads the price from the page's OpenGraph product tags
+func openGraphPrice(doc *goquery.Document, defaultCurrency string) (itemPrice models.ItemPrice, currency string) {
+	currency, _ = doc.Find("meta[property=\"product:price:currency\"], meta[property=\"og:price:currency\"]").First().Attr("content")
+	priceCurrency := defaultCurrency
+	if currency != "" {
+		priceCurrency = currency
+	}
+
+	priceString, _ := doc.Find("meta[property=\"product:price:amount\"], meta[property=\"og:price:amount\"]").First().Attr("content")
+	itemPrice.Price = parsePrice(priceString, priceCurrency)
+	if itemPrice.Price == 0 {
+		return
+	}
+
+	availableString, exists := doc.Find("meta[property=\"product:availability\"], meta[property=\"og:availability\"]").First().Attr("content")
+	// Stores that don't publish availability only tag items they sell
+	itemPrice.Available = !exists || isInStock(availableString)
+
+	originalString, _ := doc.Find("meta[property=\"product:original_price:amount\"]").First().Attr("content")
+	itemPrice.OriginalPrice = parsePrice(originalString, priceCurrency)
+	return
+}
+
+// scrapeSchemaInfo implements ScrapeInfo for stores that publish schema.org Product data,
+// preferring OpenGraph tags and falling back to the JSON-LD product
+func scrapeSchemaInfo(fetcher Fetcher, path *url.URL) (item models.Item, err error) {
+	doc, err := GetDocument(fetcher, path)
+
+	if err != nil {
+		return
+	}
+
+	return parseSchemaInfo(doc, path)
+}
+
+// parseSchemaInfo reads an item from a page that has already been fetched
+func parseSchemaInfo(doc *goquery.Document, path *url.URL) (item models.Item, err error) {
+	product, _ := findLDProduct(doc)
+
+	// Name
+	name, exists := doc.Find("meta[property=\"og:title\"]").Attr("content")
+
+	if !exists {
+		name = product.Name
+	}
+	item.Name = html.UnescapeString(name)
+
+	// Description
+	description, exists := doc.Find("meta[name=\"description\"]").Attr("content")
+
+	if !exists {
+		description = product.Description
+	}
+	item.Description = html.UnescapeString(description)
+
+	// ImageURL
+	imageURL, exists := doc.Find("meta[property=\"og:image\"]").Attr("content")
+
+	if !exists {
+		imageURL = product.imageURL()
+	}
+
+	if imageURL != "" {
+		var urlParsed *url.URL
+		urlParsed, err = url.Parse(imageURL)
+		if err != nil {
+			return
+		}
+		item.ImageURL = "https://" + urlParsed.Host + urlParsed.Path
+	}
+
+	// URL
+	item.URL = "https://" + path.Host + path.Path
+
+	// Currency
+	item.Currency = pageCurrency(doc)
+
+	// Identifiers, to match the item with the same product in other stores.
+	// ISBN-13 are GTIN-13, so normalizeISBN validates both.
+	item.GTIN = normalizeISBN(product.GTIN13)
+	item.Model = strings.TrimSpace(product.MPN)
+	return
+}
+
+// schemaStrategies are the strategies for stores that publish schema.org Product data.
+// If the page has no structured data, the price is read from the visible element matching fallback.
+func schemaStrategies(fallback string) []Strategy {
+	return []Strategy{
+		DocumentStrategy(StrategyJSONLD, jsonLDPrice),
+		DocumentStrategy(StrategyMicrodata, microdataPrice),
+		DocumentStrategy(StrategyOpenGraph, openGraphPrice),
+		SelectorStrategy(fallback),
+	}
+}
+
+// scrapeSchemaPrice implements ScrapePrice for stores that publish schema.org Product data
+func scrapeSchemaPrice(fetcher Fetcher, item models.Item, fallback string) (itemPrice models.ItemPrice, err error) {
+	return ScrapeChain(fetcher, item, schemaStrategies(fallback))
+}
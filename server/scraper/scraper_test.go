@@ -0,0 +1,254 @@
+package scraper_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+	"github.com/UN0wen/pricewatch-vn/server/scraper/scrapertest"
+)
+
+// Run with -record to refresh the fixtures from the real stores,
+// and with -update to rewrite the golden files after an intended change.
+var (
+	record = flag.Bool("record", false, "fetch the fixtures from the stores instead of replaying them")
+	update = flag.Bool("update", false, "rewrite the golden files with the scraped results")
+)
+
+const (
+	fixtureRoot = "testdata/fixtures"
+	goldenRoot  = "testdata/golden"
+	configRoot  = "configs"
+)
+
+// goldenCases is one product page for every registered scraper, and two for the generic scraper, in VND and in USD
+var goldenCases = []struct {
+	name string
+	url  string
+}{
+	{"lazada", "https://www.lazada.vn/products/dien-thoai-samsung-galaxy-a52-i1234567-s7654321.html"},
+	{"tiki", "https://tiki.vn/sach-nha-gia-kim-p123456.html"},
+	{"tiki-fallback", "https://tiki.vn/binh-giu-nhiet-lock-lock-p654321.html"},
+	{"shopee", "https://shopee.vn/Tai-nghe-Bluetooth-TWS-i12-i.111.222"},
+	{"sendo", "https://www.sendo.vn/ao-thun-nam-cotton-co-tron-12345.html"},
+	{"tgdd", "https://www.thegioididong.com/dtdd/iphone-12"},
+	{"dmx", "https://www.dienmayxanh.com/tivi/smart-tivi-samsung-4k-55-inch-ua55au8000"},
+	{"nguyenkim", "https://www.nguyenkim.com/may-giat-lg-inverter-8-5-kg-fv1408s4w.html"},
+	{"mia", "https://mia.vn/vali-keo-mia-gold-20-inch.html"},
+	{"vinabook", "https://www.vinabook.com/nha-gia-kim-p12345.html"},
+	{"vatgia", "https://vatgia.com/12345/dien-thoai-nokia-105.html"},
+	{"fptshop", "https://fptshop.com.vn/may-tinh-xach-tay/asus-vivobook-a415ea"},
+	{"generic", "https://www.example-store.vn/products/binh-giu-nhiet-500ml"},
+	{"generic-usd", "https://www.example-store.com/products/travel-mug-350ml"},
+}
+
+// golden is everything a scraper reads from a product page
+type golden struct {
+	Item          models.Item            `json:"item"`
+	Price         models.ItemPrice       `json:"price"`
+	Offers        []models.ItemOffer     `json:"offers,omitempty"`
+	Variants      []models.ItemVariant   `json:"variants,omitempty"`
+	VariantPrices []models.ItemPrice     `json:"variant_prices,omitempty"`
+	Promotions    []models.ItemPromotion `json:"promotions,omitempty"`
+	// Promotions are read separately from the price, so a store can fail to return them
+	// while the price is read by a fallback strategy
+	PromotionError string `json:"promotion_error,omitempty"`
+}
+
+// newFetcher returns the Fetcher the scrapers are tested with
+func newFetcher(t *testing.T) scraper.Fetcher {
+	if *record {
+		fetcher, err := scraper.NewHTTPFetcher(scraper.FetcherConfig{Timeout: 30 * time.Second, Retries: 2, Backoff: time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return scrapertest.NewRecorder(fetcher, fixtureRoot)
+	}
+
+	server := scrapertest.NewServer(fixtureRoot)
+	t.Cleanup(server.Close)
+	return server.Fetcher()
+}
+
+func TestGolden(t *testing.T) {
+	s, err := scraper.New(newFetcher(t), configRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range goldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found, _ := s.Get(path.Host)
+
+			var got golden
+			got.Item, err = found.ScrapeInfo(path)
+			if err != nil {
+				t.Fatalf("ScrapeInfo: %s", err)
+			}
+
+			got.Price, got.Offers, err = scraper.ScrapeListing(context.Background(), found, got.Item)
+			if err != nil {
+				t.Fatalf("ScrapeListing: %s", err)
+			}
+
+			if variantScraper, ok := found.(scraper.VariantScraper); ok {
+				got.Variants, got.VariantPrices, err = variantScraper.ScrapeVariants(context.Background(), got.Item)
+				if err != nil {
+					t.Fatalf("ScrapeVariants: %s", err)
+				}
+			}
+
+			if promotionScraper, ok := found.(scraper.PromotionScraper); ok {
+				got.Promotions, err = promotionScraper.ScrapePromotions(context.Background(), got.Item)
+				if err != nil {
+					got.PromotionError = err.Error()
+				}
+			}
+
+			compareGolden(t, filepath.Join(goldenRoot, tc.name+".json"), got)
+		})
+	}
+}
+
+// TestScrapeCancelled checks that a cancelled context stops the Lazada and Tiki scrapers, the first golden cases,
+// and their offers before they fetch anything
+func TestScrapeCancelled(t *testing.T) {
+	server := scrapertest.NewServer(fixtureRoot)
+	t.Cleanup(server.Close)
+
+	s, err := scraper.New(server.Fetcher(), configRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, tc := range goldenCases[:2] {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found, _ := s.Get(path.Host)
+			if _, ok := found.(scraper.ContextScraper); !ok {
+				t.Fatalf("%T does not implement ContextScraper", found)
+			}
+
+			if _, err = scraper.ScrapeInfo(ctx, found, path); !errors.Is(err, context.Canceled) {
+				t.Errorf("ScrapeInfo: got %v, want context.Canceled", err)
+			}
+
+			if _, err = scraper.ScrapePrice(ctx, found, models.Item{URL: tc.url}); !errors.Is(err, context.Canceled) {
+				t.Errorf("ScrapePrice: got %v, want context.Canceled", err)
+			}
+
+			if _, _, err = scraper.ScrapeListing(ctx, found, models.Item{URL: tc.url}); !errors.Is(err, context.Canceled) {
+				t.Errorf("ScrapeListing: got %v, want context.Canceled", err)
+			}
+
+			if offerScraper, ok := found.(scraper.OfferScraper); ok {
+				if _, err = offerScraper.ScrapeOffers(ctx, models.Item{URL: tc.url}); !errors.Is(err, context.Canceled) {
+					t.Errorf("ScrapeOffers: got %v, want context.Canceled", err)
+				}
+			}
+		})
+	}
+}
+
+// TestScrapeDownloadsOnce checks that the Lazada and Tiki scrapers read the price, offers, variants and promotions
+// of an item from a single request under a context from WithDownloads
+func TestScrapeDownloadsOnce(t *testing.T) {
+	for _, tc := range goldenCases[:2] {
+		t.Run(tc.name, func(t *testing.T) {
+			server := scrapertest.NewServer(fixtureRoot)
+			t.Cleanup(server.Close)
+
+			s, err := scraper.New(server.Fetcher(), configRoot)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found, _ := s.Get(path.Host)
+			item := models.Item{URL: tc.url, Currency: scraper.DefaultCurrency}
+			ctx := scraper.WithDownloads(context.Background())
+
+			if _, _, err = scraper.ScrapeListing(ctx, found, item); err != nil {
+				t.Fatalf("ScrapeListing: %s", err)
+			}
+			if variantScraper, ok := found.(scraper.VariantScraper); ok {
+				if _, _, err = variantScraper.ScrapeVariants(ctx, item); err != nil {
+					t.Fatalf("ScrapeVariants: %s", err)
+				}
+			}
+			if promotionScraper, ok := found.(scraper.PromotionScraper); ok {
+				if _, err = promotionScraper.ScrapePromotions(ctx, item); err != nil {
+					t.Fatalf("ScrapePromotions: %s", err)
+				}
+			}
+
+			if requests := server.Requests(); requests != 1 {
+				t.Errorf("got %d requests, want 1", requests)
+			}
+		})
+	}
+}
+
+// TestSearch checks the merged results of the stores' searches against the golden file
+func TestSearch(t *testing.T) {
+	s, err := scraper.New(newFetcher(t), configRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := s.Search(context.Background(), "binh giu nhiet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compareGolden(t, filepath.Join(goldenRoot, "search.json"), results)
+}
+
+// compareGolden checks got against the golden file, or rewrites the file with -update
+func compareGolden(t *testing.T, filename string, got interface{}) {
+	actual, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual = append(actual, '\n')
+
+	if *update {
+		if err = ioutil.WriteFile(filename, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Cannot read golden file, run with -update to create it: %s", err)
+	}
+
+	if string(actual) != string(expected) {
+		t.Errorf("Scraped result differs from %s\ngot:\n%s\nwant:\n%s", filename, actual, expected)
+	}
+}
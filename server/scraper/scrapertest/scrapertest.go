@@ -0,0 +1,163 @@
+// Package scrapertest serves recorded store pages to the scrapers, so they can be tested without the network.
+//
+// Fixtures are stored as testdata/fixtures/<host>/<name>, where the name is derived from the
+// path and query of the URL by FixtureName. A Recorder captures new fixtures from the real stores.
+package scrapertest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+	"github.com/pkg/errors"
+)
+
+// FixtureName returns the file name of the fixture for the path and query of an URL
+func FixtureName(u *url.URL) string {
+	name := strings.ReplaceAll(strings.Trim(u.Path, "/"), "/", "__")
+	if name == "" {
+		name = "index"
+	}
+
+	if u.RawQuery != "" {
+		name += "@" + url.QueryEscape(u.RawQuery)
+	}
+	return name
+}
+
+// FixturePath returns where the fixture for an URL is stored under root
+func FixturePath(root string, u *url.URL) string {
+	return filepath.Join(root, u.Host, FixtureName(u))
+}
+
+// Server serves the fixtures in a folder over HTTP.
+// Every fixture has an ETag, and conditional requests for an unchanged fixture get a 304.
+type Server struct {
+	*httptest.Server
+	root     string
+	requests int64
+}
+
+// NewServer starts a Server for the fixtures in root. It must be closed after use.
+func NewServer(root string) *Server {
+	s := &Server{root: root}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// serve returns the fixture for a request that was routed by the server's Fetcher.
+// The store's host is the first segment of the request path.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.requests, 1)
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	original := &url.URL{Host: parts[0], RawQuery: r.URL.RawQuery}
+	if len(parts) > 1 {
+		original.Path = "/" + parts[1]
+	}
+
+	body, err := ioutil.ReadFile(FixturePath(s.root, original))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("No fixture for %s: %s", original.String(), err), http.StatusNotFound)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(body))
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(body))
+	w.Write(body)
+}
+
+// Requests returns the number of requests the server received
+func (s *Server) Requests() int {
+	return int(atomic.LoadInt64(&s.requests))
+}
+
+// Fetcher returns a Fetcher that sends every request to the server instead of the store
+func (s *Server) Fetcher() scraper.Fetcher {
+	return serverFetcher{server: s}
+}
+
+// serverFetcher routes requests to a Server
+type serverFetcher struct {
+	server *Server
+}
+
+// Do rewrites the request to the fixture server, keeping the store's host in the path
+// Like the HTTPFetcher, it only returns an error for 4xx and 5xx responses.
+func (f serverFetcher) Do(req *http.Request) (resp *http.Response, err error) {
+	target, err := url.Parse(f.server.URL)
+	if err != nil {
+		return
+	}
+
+	routed := req.Clone(req.Context())
+	routed.URL.Scheme = target.Scheme
+	routed.URL.Path = "/" + req.URL.Host + req.URL.Path
+	routed.URL.RawPath = ""
+	routed.URL.Host = target.Host
+	routed.Host = target.Host
+
+	resp, err = f.server.Client().Do(routed)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		err = &scraper.StatusError{URL: req.URL.String(), StatusCode: resp.StatusCode, Status: resp.Status}
+		return nil, err
+	}
+	return
+}
+
+// Recorder is a Fetcher that saves every response of another Fetcher as a fixture
+type Recorder struct {
+	fetcher scraper.Fetcher
+	root    string
+}
+
+// NewRecorder creates a Recorder that saves the responses of fetcher under root
+func NewRecorder(fetcher scraper.Fetcher, root string) *Recorder {
+	return &Recorder{fetcher: fetcher, root: root}
+}
+
+// Do sends the request and saves the response body before returning it
+func (r *Recorder) Do(req *http.Request) (resp *http.Response, err error) {
+	resp, err = r.fetcher.Do(req)
+	if err != nil {
+		return
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot read the response of %s", req.URL.String())
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	filename := FixturePath(r.root, req.URL)
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, errors.Wrapf(err, "Cannot create the fixture folder for %s", req.URL.String())
+	}
+
+	if err = ioutil.WriteFile(filename, body, 0644); err != nil {
+		return nil, errors.Wrapf(err, "Cannot save the fixture for %s", req.URL.String())
+	}
+	return
+}
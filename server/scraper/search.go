@@ -0,0 +1,106 @@
+package scraper
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/pkg/errors"
+)
+
+// SearchLimit is the number of results a Searcher returns for a query
+const SearchLimit = 10
+
+// Searcher is implemented by scrapers of stores with a keyword search.
+// results are in the store's order of relevance.
+type Searcher interface {
+	Scraper
+	Search(ctx context.Context, query string) (results []SearchResult, err error)
+}
+
+// SearchResult is a product found by a keyword search, which can be tracked with its URL
+type SearchResult struct {
+	Store    string `json:"store"` // host of the store's scraper
+	Name     string `json:"name"`
+	Price    int64  `json:"price"`
+	Currency string `json:"currency"`
+	URL      string `json:"url"`
+	ImageURL string `json:"image_url"`
+	StoreKey string `json:"store_key"` // canonical key of the product, to find it among the tracked items
+}
+
+// Search runs a keyword search on every store whose scraper is a Searcher, all at once.
+// The results of the stores are interleaved, so the most relevant result of every store comes first.
+// Failed stores are logged and skipped, err is only set if every store failed.
+func (s *scraper) Search(ctx context.Context, query string) (results []SearchResult, err error) {
+	var hosts []string
+	for host, found := range s.Scrapers {
+		if _, ok := found.(Searcher); ok {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+
+	timeout, _ := strconv.Atoi(utils.ScraperTimeout)
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	storeResults := make([][]SearchResult, len(hosts))
+	storeErrors := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, searcher Searcher) {
+			defer wg.Done()
+			storeResults[i], storeErrors[i] = searcher.Search(ctx, query)
+		}(i, s.Scrapers[host].(Searcher))
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, host := range hosts {
+		if storeErrors[i] != nil {
+			failed++
+			utils.Sugar.Errorf("Search for %s failed on %s: %s", query, host, storeErrors[i])
+		}
+	}
+
+	if len(hosts) > 0 && failed == len(hosts) {
+		err = errors.Wrapf(storeErrors[0], "Search for %s failed on every store", query)
+		return
+	}
+
+	seen := make(map[string]bool)
+	for rank := 0; ; rank++ {
+		more := false
+		for i, host := range hosts {
+			if rank >= len(storeResults[i]) {
+				continue
+			}
+			more = true
+
+			result := storeResults[i][rank]
+			canonical, e := s.Canonicalize(result.URL)
+			if e != nil || seen[canonical.Key] {
+				continue
+			}
+			seen[canonical.Key] = true
+
+			result.Store = host
+			result.URL = canonical.URL.String()
+			result.StoreKey = canonical.Key
+			if result.Currency == "" {
+				result.Currency = DefaultCurrency
+			}
+			results = append(results, result)
+		}
+
+		if !more {
+			break
+		}
+	}
+	return
+}
@@ -1 +1,104 @@
 package scraper
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+)
+
+// sendoProductID matches the product id in a Sendo URL, e.g. /dien-thoai-123.html
+var sendoProductID = regexp.MustCompile(`-(\d+)\.html$`)
+
+// SendoScraper holds the Fetcher for the methods that implements Scraper
+type SendoScraper struct {
+	Fetcher Fetcher
+}
+
+// ScrapeInfo reads the item's name, description and image from the OpenGraph tags of the Sendo page
+func (s SendoScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	doc, err := GetDocument(s.Fetcher, path)
+
+	if err != nil {
+		return
+	}
+
+	// Name
+	name, exists := doc.Find("meta[property=\"og:title\"]").Attr("content")
+
+	if exists {
+		name = html.UnescapeString(name)
+		item.Name = name
+	} else {
+		item.Name = strings.TrimSpace(doc.Find("h1").First().Text())
+	}
+
+	// Description
+	description, exists := doc.Find("meta[name=\"description\"]").Attr("content")
+
+	if exists {
+		description = html.UnescapeString(description)
+		item.Description = description
+	}
+
+	// ImageURL
+	imageURL, exists := doc.Find("meta[property=\"og:image\"]").Attr("content")
+
+	if exists {
+		var urlParsed *url.URL
+		urlParsed, err = url.Parse(imageURL)
+		if err != nil {
+			return
+		}
+		item.ImageURL = "https://" + urlParsed.Host + urlParsed.Path
+	}
+
+	// URL
+	item.URL = "https://" + path.Host + path.Path
+
+	// Currency
+	item.Currency = pageCurrency(doc)
+	return
+}
+
+// ScrapePrice returns the current price for an item
+func (s SendoScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
+	return ScrapeChain(s.Fetcher, item, s.Strategies())
+}
+
+// Strategies returns the ways Sendo's price is read.
+// The structured data offer already has the discount applied.
+func (s SendoScraper) Strategies() []Strategy {
+	return []Strategy{
+		DocumentStrategy(StrategyJSONLD, jsonLDPrice),
+		DocumentStrategy(StrategyMicrodata, microdataPrice),
+		DocumentStrategy(StrategyOpenGraph, openGraphPrice),
+		DocumentStrategy(StrategyHTML, sendoVisiblePrice),
+	}
+}
+
+// sendoVisiblePrice reads the final price shown next to the buy button
+func sendoVisiblePrice(doc *goquery.Document, defaultCurrency string) (itemPrice models.ItemPrice, currency string) {
+	itemPrice.Price = parsePrice(doc.Find("[class*=\"currentPrice\"]").First().Text(), defaultCurrency)
+	itemPrice.Available = itemPrice.Price > 0 && doc.Find("[class*=\"outOfStock\"]").Length() == 0
+	itemPrice.OriginalPrice = parsePrice(doc.Find("[class*=\"oldPrice\"]").First().Text(), defaultCurrency)
+	itemPrice.Seller = strings.TrimSpace(doc.Find("[class*=\"shopName\"]").First().Text())
+	return
+}
+
+// GetHost returns the host name for the scraper
+func (s SendoScraper) GetHost() (host string) {
+	host = "www.sendo.vn"
+	return
+}
+
+// ProductID returns the store's stable id for the product at path
+func (s SendoScraper) ProductID(path *url.URL) string {
+	if match := sendoProductID.FindStringSubmatch(path.Path); match != nil {
+		return match[1]
+	}
+	return ""
+}
@@ -1 +1,205 @@
 package scraper
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/pkg/errors"
+)
+
+// ShopeePriceDivisor is the factor Shopee multiplies all of its prices by
+const ShopeePriceDivisor = 100000
+
+// shopeePrice converts a price published by Shopee to the minor unit of currency
+func shopeePrice(price int64, currency string) int64 {
+	return toMinor(float64(price)/ShopeePriceDivisor, currency)
+}
+
+// shopeeAPI is the endpoint that returns the product JSON for a shop and item id
+const shopeeAPI = "https://shopee.vn/api/v2/item/get?itemid=%s&shopid=%s"
+
+// shopeeSearchAPI is the endpoint that returns the items matching a keyword, with the query and the number of results
+const shopeeSearchAPI = "https://shopee.vn/api/v4/search/search_items?by=relevancy&keyword=%s&limit=%d&newest=0"
+
+// shopeeImageHost is the CDN that serves Shopee's product images
+const shopeeImageHost = "https://cf.shopee.vn/file/"
+
+// shopeeIDs matches the -i.<shopid>.<itemid> suffix of a Shopee product URL
+var shopeeIDs = regexp.MustCompile(`-i\.(\d+)\.(\d+)$`)
+
+// ShopeeScraper holds the Fetcher for the methods that implements Scraper
+type ShopeeScraper struct {
+	Fetcher Fetcher
+}
+
+// shopeeItem is the part of Shopee's product JSON used by the scraper
+type shopeeItem struct {
+	Item *struct {
+		Name                string `json:"name"`
+		Description         string `json:"description"`
+		Image               string `json:"image"`
+		Currency            string `json:"currency"`
+		Price               int64  `json:"price"`
+		PriceMin            int64  `json:"price_min"`
+		Stock               int64  `json:"stock"`
+		PriceBeforeDiscount int64  `json:"price_before_discount"`
+		RawDiscount         int64  `json:"raw_discount"`
+		ItemRating          struct {
+			RatingStar  float64 `json:"rating_star"`
+			RatingCount []int64 `json:"rating_count"` // the total, then the count of every star
+		} `json:"item_rating"`
+	} `json:"item"`
+}
+
+// shopeeSearch is the part of Shopee's search JSON used by the scraper
+type shopeeSearch struct {
+	Items []struct {
+		ItemBasic struct {
+			ItemID   int64  `json:"itemid"`
+			ShopID   int64  `json:"shopid"`
+			Name     string `json:"name"`
+			Image    string `json:"image"`
+			Currency string `json:"currency"`
+			Price    int64  `json:"price"`
+		} `json:"item_basic"`
+	} `json:"items"`
+}
+
+// getShopeeItem fetches the product JSON for a Shopee product URL
+func getShopeeItem(fetcher Fetcher, path *url.URL) (data shopeeItem, err error) {
+	ids := shopeeIDs.FindStringSubmatch(path.Path)
+	if ids == nil {
+		err = errors.New(fmt.Sprintf("Cannot find the shop and item id in Shopee url %s", path.String()))
+		return
+	}
+
+	err = GetJSON(fetcher, fmt.Sprintf(shopeeAPI, ids[2], ids[1]), &data)
+	if err != nil {
+		return
+	}
+
+	if data.Item == nil {
+		err = errors.New(fmt.Sprintf("Shopee returned no item for url %s", path.String()))
+	}
+	return
+}
+
+// ScrapeInfo reads the item's name, description, image and currency from Shopee's product API
+func (s ShopeeScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	data, err := getShopeeItem(s.Fetcher, path)
+
+	if err != nil {
+		return
+	}
+
+	// Name
+	item.Name = html.UnescapeString(data.Item.Name)
+
+	// Description
+	item.Description = html.UnescapeString(data.Item.Description)
+
+	// ImageURL
+	if data.Item.Image != "" {
+		item.ImageURL = shopeeImageHost + data.Item.Image
+	}
+
+	// URL
+	item.URL = "https://" + path.Host + path.Path
+
+	// Currency
+	item.Currency = DefaultCurrency
+	if data.Item.Currency != "" {
+		item.Currency = strings.ToUpper(data.Item.Currency)
+	}
+	return
+}
+
+// ScrapePrice returns the current price for an item
+func (s ShopeeScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
+	sanitized, err := url.Parse(item.URL)
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL provided")
+		return
+	}
+
+	data, err := getShopeeItem(s.Fetcher, sanitized)
+
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot parse json from Shopee from URL %s", item.URL)
+		return
+	}
+
+	// Items with variants report the cheapest variant in price_min
+	price := data.Item.Price
+	if data.Item.PriceMin > 0 {
+		price = data.Item.PriceMin
+	}
+	price = shopeePrice(price, item.Currency)
+
+	if price == int64(0) {
+		err = errors.New(fmt.Sprintf("Cannot parse price for Shopee with url %s", item.URL))
+		return
+	}
+
+	itemPrice.Price = price
+	itemPrice.Available = data.Item.Stock > 0
+	itemPrice.Strategy = StrategyAPI
+	itemPrice.OriginalPrice = shopeePrice(data.Item.PriceBeforeDiscount, item.Currency)
+	itemPrice.Discount = data.Item.RawDiscount
+	itemPrice.Rating = data.Item.ItemRating.RatingStar
+	if len(data.Item.ItemRating.RatingCount) > 0 {
+		itemPrice.ReviewCount = data.Item.ItemRating.RatingCount[0]
+	}
+	setDiscount(&itemPrice)
+	return
+}
+
+// Search returns the items of Shopee's search for query
+func (s ShopeeScraper) Search(ctx context.Context, query string) (results []SearchResult, err error) {
+	var search shopeeSearch
+	err = GetJSONContext(ctx, s.Fetcher, fmt.Sprintf(shopeeSearchAPI, url.QueryEscape(query), SearchLimit), &search)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot search Shopee for %s", query)
+		return
+	}
+
+	for _, found := range search.Items {
+		item := found.ItemBasic
+		if item.ItemID == 0 || item.ShopID == 0 {
+			continue
+		}
+
+		// Product URLs are the item's name followed by -i.<shopid>.<itemid>
+		slug := url.PathEscape(strings.Join(strings.Fields(item.Name), "-"))
+		result := SearchResult{
+			Name:     html.UnescapeString(item.Name),
+			Price:    shopeePrice(item.Price, item.Currency),
+			Currency: strings.ToUpper(item.Currency),
+			URL:      fmt.Sprintf("https://shopee.vn/%s-i.%d.%d", slug, item.ShopID, item.ItemID),
+		}
+		if item.Image != "" {
+			result.ImageURL = shopeeImageHost + item.Image
+		}
+		results = append(results, result)
+	}
+	return
+}
+
+// GetHost returns the host name for the scraper
+func (s ShopeeScraper) GetHost() (host string) {
+	host = "shopee.vn"
+	return
+}
+
+// ProductID returns the store's stable id for the product at path
+func (s ShopeeScraper) ProductID(path *url.URL) string {
+	if ids := shopeeIDs.FindStringSubmatch(path.Path); ids != nil {
+		return ids[1] + "." + ids[2]
+	}
+	return ""
+}
@@ -0,0 +1,149 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/pkg/errors"
+)
+
+// Strategies that don't read the page's structured data
+const (
+	StrategyAPI        = "api"         // the store's JSON API
+	StrategyModuleData = "module-data" // page data embedded in a script for the store's frontend
+	StrategyHTML       = "html"        // visible elements of the page
+)
+
+// maxPrice is the highest price accepted from a strategy, anything above is a parsing mistake
+const maxPrice = 100000000000
+
+// Page is the item whose price is being scraped.
+// Its product page is downloaded once, by the first strategy that needs it.
+type Page struct {
+	Item    models.Item
+	URL     *url.URL
+	ctx     context.Context
+	fetcher Fetcher
+	doc     *goquery.Document
+	err     error
+	loaded  bool
+}
+
+// Document returns the item's product page
+func (p *Page) Document() (doc *goquery.Document, err error) {
+	if !p.loaded {
+		p.doc, p.err = GetDocumentContext(p.ctx, p.fetcher, p.URL)
+		p.loaded = true
+	}
+	return p.doc, p.err
+}
+
+// Context returns the context the scrape is cancelled with.
+// Strategies that send their own requests should send them with it.
+func (p *Page) Context() context.Context {
+	return p.ctx
+}
+
+// Strategy is one way of reading the price of an item, e.g. from the store's JSON API or the page's JSON-LD
+type Strategy struct {
+	Name  string
+	Price func(page *Page) (itemPrice models.ItemPrice, err error)
+}
+
+// StrategyScraper is implemented by scrapers that read prices through an ordered chain of strategies.
+// The first strategy that returns a valid price wins.
+type StrategyScraper interface {
+	Scraper
+	Strategies() []Strategy
+}
+
+// ScrapeChain tries the strategies in order and returns the first valid price,
+// with the name of the strategy that read it.
+func ScrapeChain(fetcher Fetcher, item models.Item, strategies []Strategy) (itemPrice models.ItemPrice, err error) {
+	return ScrapeChainContext(context.Background(), fetcher, item, strategies)
+}
+
+// ScrapeChainContext is ScrapeChain with a context. Once ctx is done, the remaining strategies are not tried.
+func ScrapeChainContext(ctx context.Context, fetcher Fetcher, item models.Item, strategies []Strategy) (itemPrice models.ItemPrice, err error) {
+	sanitized, err := url.Parse(item.URL)
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL provided")
+		return
+	}
+
+	page := &Page{Item: item, URL: sanitized, ctx: ctx, fetcher: fetcher}
+	var failures []string
+	for _, strategy := range strategies {
+		if ctx.Err() != nil {
+			err = errors.Wrapf(ctx.Err(), "Scrape of %s stopped", item.URL)
+			return
+		}
+
+		price, e := strategy.Price(page)
+		if e == nil {
+			e = validatePrice(price)
+		}
+
+		if e != nil {
+			utils.Sugar.Infof("Strategy %s failed for %s: %s", strategy.Name, item.URL, e)
+			failures = append(failures, strategy.Name+": "+e.Error())
+			continue
+		}
+
+		price.Strategy = strategy.Name
+		setDiscount(&price)
+		return price, nil
+	}
+
+	// Keep the download error as the cause, so it isn't mistaken for a page that changed
+	if page.err != nil {
+		err = errors.Wrapf(page.err, "Cannot download %s (%s)", item.URL, strings.Join(failures, "; "))
+		return
+	}
+
+	err = errors.New(fmt.Sprintf("Cannot parse price for %s with url %s (%s)", sanitized.Host, item.URL, strings.Join(failures, "; ")))
+	return
+}
+
+// validatePrice checks that a strategy read a plausible price
+func validatePrice(itemPrice models.ItemPrice) (err error) {
+	if itemPrice.Price <= 0 {
+		return errors.New("No price found")
+	}
+
+	if itemPrice.Price >= maxPrice {
+		return errors.New(fmt.Sprintf("Implausible price %d", itemPrice.Price))
+	}
+	return
+}
+
+// DocumentStrategy creates a strategy from a DocumentReader, such as the structured data readers
+func DocumentStrategy(name string, read DocumentReader) Strategy {
+	return Strategy{
+		Name: name,
+		Price: func(page *Page) (itemPrice models.ItemPrice, err error) {
+			doc, err := page.Document()
+			if err != nil {
+				return
+			}
+
+			itemPrice, _ = read(doc, page.Item.Currency)
+			return
+		},
+	}
+}
+
+// SelectorStrategy creates a strategy that reads the visible price of the first element matching selector.
+// The item is considered available if it has a price.
+func SelectorStrategy(selector string) Strategy {
+	return DocumentStrategy(StrategyHTML, func(doc *goquery.Document, defaultCurrency string) (itemPrice models.ItemPrice, currency string) {
+		itemPrice.Price = parsePrice(doc.Find(selector).First().Text(), defaultCurrency)
+		itemPrice.Available = itemPrice.Price > 0
+		return
+	})
+}
@@ -1 +1,28 @@
 package scraper
+
+import (
+	"net/url"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+)
+
+// TGDDScraper holds the Fetcher for the methods that implements Scraper for Thế Giới Di Động
+type TGDDScraper struct {
+	Fetcher Fetcher
+}
+
+// ScrapeInfo reads the item from the Mobile World page of Thế Giới Di Động
+func (s TGDDScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	return scrapeMobileWorldInfo(s.Fetcher, path)
+}
+
+// ScrapePrice returns the current price for an item
+func (s TGDDScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
+	return scrapeMobileWorldPrice(s.Fetcher, item)
+}
+
+// GetHost returns the host name for the scraper
+func (s TGDDScraper) GetHost() (host string) {
+	host = "www.thegioididong.com"
+	return
+}
@@ -1,26 +1,97 @@
 package scraper
 
 import (
+	"context"
+	"fmt"
 	"html"
 	"net/url"
-	"strconv"
+	"regexp"
+	"time"
 
 	"github.com/UN0wen/pricewatch-vn/server/api/models"
 	"github.com/pkg/errors"
 )
 
-// TikiScraper is an empty struct to hold methods that implements Scraper
-type TikiScraper struct{}
+// tikiProductID matches the product id in a Tiki URL, e.g. /dien-thoai-p123.html
+var tikiProductID = regexp.MustCompile(`-p(\d+)\.html$`)
+
+// tikiAPI is the endpoint that returns the product JSON for a product id
+const tikiAPI = "https://tiki.vn/api/v2/products/%s"
+
+// tikiSearchAPI is the endpoint that returns the products matching a keyword, with the number of results and the query
+const tikiSearchAPI = "https://tiki.vn/api/v2/products?limit=%d&q=%s"
+
+// tikiPrice is the visible price element used when the API and structured data fail
+const tikiPrice = ".product-price__current-price"
+
+// tikiTradingID is the seller id of Tiki itself
+const tikiTradingID = 1
+
+// tikiOfficialStore is the store level of the brands' official stores
+const tikiOfficialStore = "OFFICIAL_STORE"
+
+// tikiProduct is the part of Tiki's product JSON used by the scraper
+type tikiProduct struct {
+	Price           int64        `json:"price"`
+	ListPrice       int64        `json:"list_price"`
+	DiscountRate    int64        `json:"discount_rate"`
+	InventoryStatus string       `json:"inventory_status"`
+	RatingAverage   float64      `json:"rating_average"`
+	ReviewCount     int64        `json:"review_count"`
+	CurrentSeller   *tikiSeller  `json:"current_seller"`
+	OtherSellers    []tikiSeller `json:"other_sellers"`
+	FlashDeal       *struct {
+		Name      string `json:"name"`
+		Price     int64  `json:"price"`
+		StartTime int64  `json:"start_time"` // in seconds
+		EndTime   int64  `json:"end_time"`
+	} `json:"flash_deal"`
+}
+
+// tikiSearch is the part of Tiki's search JSON used by the scraper
+type tikiSearch struct {
+	Data []struct {
+		Name         string `json:"name"`
+		URLPath      string `json:"url_path"` // e.g. sach-nha-gia-kim-p123456.html
+		Price        int64  `json:"price"`
+		ThumbnailURL string `json:"thumbnail_url"`
+	} `json:"data"`
+}
+
+// tikiSeller is a seller of a product in Tiki's product JSON.
+// Price is only set for the other sellers, the current seller sells at the product's price.
+type tikiSeller struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Link       string `json:"link"`
+	Price      int64  `json:"price"`
+	StoreLevel string `json:"store_level"`
+}
+
+// official checks if the seller is Tiki or a brand's official store
+func (seller tikiSeller) official() bool {
+	return seller.ID == tikiTradingID || seller.StoreLevel == tikiOfficialStore
+}
+
+// TikiScraper holds the Fetcher for the methods that implements Scraper
+type TikiScraper struct {
+	Fetcher Fetcher
+}
 
 // ScrapeInfo extracts the required information out of a page from the scraper config
 func (s TikiScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	return s.ScrapeInfoContext(context.Background(), path)
+}
+
+// ScrapeInfoContext is ScrapeInfo with a context that cancels the download of the page
+func (s TikiScraper) ScrapeInfoContext(ctx context.Context, path *url.URL) (item models.Item, err error) {
 	// sanitized, err := url.Parse(path)
 	// if err != nil {
 	// 	err = errors.Wrapf(err, "Invalid URL provided")
 	// 	return
 	// }
 
-	doc, err := GetDocument(path)
+	doc, err := GetDocumentContext(ctx, s.Fetcher, path)
 
 	if err != nil {
 		return
@@ -58,40 +129,154 @@ func (s TikiScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
 	item.URL = "https://" + path.Host + path.Path
 
 	// Currency
-	item.Currency = "VND"
+	item.Currency = pageCurrency(doc)
 	return
 }
 
 // ScrapePrice returns the current price for an item
 func (s TikiScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
-	var price int64
-	var available bool
+	return s.ScrapePriceContext(context.Background(), item)
+}
+
+// ScrapePriceContext is ScrapePrice with a context that cancels the requests of the strategies
+func (s TikiScraper) ScrapePriceContext(ctx context.Context, item models.Item) (itemPrice models.ItemPrice, err error) {
+	return ScrapeChainContext(ctx, s.Fetcher, item, s.Strategies())
+}
+
+// Strategies returns the ways Tiki's price is read, starting with the product API
+func (s TikiScraper) Strategies() []Strategy {
+	return []Strategy{
+		{Name: StrategyAPI, Price: s.apiPrice},
+		DocumentStrategy(StrategyJSONLD, jsonLDPrice),
+		DocumentStrategy(StrategyMicrodata, microdataPrice),
+		SelectorStrategy(tikiPrice),
+	}
+}
+
+// product gets the product at path from Tiki's product API
+func (s TikiScraper) product(ctx context.Context, path *url.URL) (product tikiProduct, err error) {
+	productID := s.ProductID(path)
+	if productID == "" {
+		err = errors.New(fmt.Sprintf("Cannot find the product id in Tiki url %s", path.String()))
+		return
+	}
+
+	err = GetJSONContext(ctx, s.Fetcher, fmt.Sprintf(tikiAPI, productID), &product)
+	return
+}
+
+// apiPrice reads the price from Tiki's product API
+func (s TikiScraper) apiPrice(page *Page) (itemPrice models.ItemPrice, err error) {
+	product, err := s.product(page.Context(), page.URL)
+	if err != nil {
+		return
+	}
+
+	itemPrice.Price = product.Price
+	itemPrice.Available = product.InventoryStatus == "available"
+	itemPrice.OriginalPrice = product.ListPrice
+	itemPrice.Discount = product.DiscountRate
+	itemPrice.Rating = product.RatingAverage
+	itemPrice.ReviewCount = product.ReviewCount
+	if product.CurrentSeller != nil {
+		itemPrice.Seller = product.CurrentSeller.Name
+	}
+	return
+}
+
+// ScrapeOffers returns the offer of the current seller and of every other seller of the product
+func (s TikiScraper) ScrapeOffers(ctx context.Context, item models.Item) (offers []models.ItemOffer, err error) {
 	sanitized, err := url.Parse(item.URL)
 	if err != nil {
 		err = errors.Wrapf(err, "Invalid URL provided")
 		return
 	}
 
-	doc, err := GetDocument(sanitized)
-
-	priceString, exists := doc.Find("meta[itemProp=\"price\"]").Attr("content")
+	product, err := s.product(ctx, sanitized)
+	if err != nil {
+		return
+	}
 
-	if exists {
-		price, err = strconv.ParseInt(priceString, 10, 64)
+	if product.CurrentSeller != nil && product.Price > 0 {
+		offers = append(offers, models.ItemOffer{
+			Seller:    product.CurrentSeller.Name,
+			URL:       item.URL,
+			Price:     product.Price,
+			Available: product.InventoryStatus == "available",
+			Official:  product.CurrentSeller.official(),
+		})
+	}
 
-		if err != nil {
-			return
+	// Other sellers are only listed while they have the product in stock
+	for _, seller := range product.OtherSellers {
+		if seller.Name == "" || seller.Price == 0 {
+			continue
 		}
+
+		offers = append(offers, models.ItemOffer{
+			Seller:    seller.Name,
+			URL:       seller.Link,
+			Price:     seller.Price,
+			Available: true,
+			Official:  seller.official(),
+		})
 	}
 
-	availableString, exists := doc.Find("link[itemProp=\"availability\"]").Attr("href")
+	if len(offers) == 0 {
+		err = errors.New(fmt.Sprintf("Cannot find any seller for Tiki url %s", item.URL))
+	}
+	return
+}
 
-	if exists {
-		available = availableString == InStockHTTP
+// ScrapePromotions returns the product's flash deal, if it has one
+func (s TikiScraper) ScrapePromotions(ctx context.Context, item models.Item) (promotions []models.ItemPromotion, err error) {
+	sanitized, err := url.Parse(item.URL)
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL provided")
+		return
 	}
 
-	itemPrice.Price = price
-	itemPrice.Available = available
+	product, err := s.product(ctx, sanitized)
+	if err != nil {
+		return
+	}
+
+	deal := product.FlashDeal
+	if deal == nil || deal.Price == 0 || deal.EndTime == 0 {
+		return
+	}
+
+	promotions = append(promotions, models.ItemPromotion{
+		Label:        deal.Name,
+		Price:        deal.Price,
+		RegularPrice: product.Price,
+		StartsAt:     time.Unix(deal.StartTime, 0).UTC(),
+		EndsAt:       time.Unix(deal.EndTime, 0).UTC(),
+	})
+	return
+}
+
+// Search returns the products of Tiki's search for query
+func (s TikiScraper) Search(ctx context.Context, query string) (results []SearchResult, err error) {
+	var search tikiSearch
+	err = GetJSONContext(ctx, s.Fetcher, fmt.Sprintf(tikiSearchAPI, SearchLimit, url.QueryEscape(query)), &search)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot search Tiki for %s", query)
+		return
+	}
+
+	for _, product := range search.Data {
+		if product.URLPath == "" {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Name:     html.UnescapeString(product.Name),
+			Price:    product.Price,
+			URL:      "https://tiki.vn/" + product.URLPath,
+			ImageURL: product.ThumbnailURL,
+		})
+	}
 	return
 }
 
@@ -100,3 +285,11 @@ func (s TikiScraper) GetHost() (host string) {
 	host = "tiki.vn"
 	return
 }
+
+// ProductID returns the store's stable id for the product at path
+func (s TikiScraper) ProductID(path *url.URL) string {
+	if match := tikiProductID.FindStringSubmatch(path.Path); match != nil {
+		return match[1]
+	}
+	return ""
+}
@@ -1 +1,104 @@
 package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/pkg/errors"
+)
+
+// Selectors for the list of shops on a Vatgia product page
+const (
+	vatgiaOffer   = "#compare_estore_list .estore_item, .compare_estore .item"
+	vatgiaSeller  = ".estore_name"
+	vatgiaPrice   = ".price"
+	vatgiaLink    = "a.buy_button, .estore_name a"
+	vatgiaSoldOut = ".out_of_stock"
+)
+
+// vatgiaProductID matches the product id in a Vatgia URL, e.g. /123/dien-thoai.html
+var vatgiaProductID = regexp.MustCompile(`^/(\d+)/`)
+
+// VatgiaScraper holds the Fetcher for the methods that implements OfferScraper for Vatgia
+type VatgiaScraper struct {
+	Fetcher Fetcher
+}
+
+// ScrapeInfo reads the item from the schema.org data of the Vatgia page
+func (s VatgiaScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	return scrapeSchemaInfo(s.Fetcher, path)
+}
+
+// ScrapePrice returns the price of the best offer for an item
+func (s VatgiaScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
+	offers, err := s.ScrapeOffers(context.Background(), item)
+	if err != nil {
+		return
+	}
+	return s.priceOf(offers)
+}
+
+// priceOf returns the price of the best of the offers read from the product page
+func (s VatgiaScraper) priceOf(offers []models.ItemOffer) (itemPrice models.ItemPrice, err error) {
+	itemPrice, err = BestOffer(offers)
+	itemPrice.Strategy = StrategyHTML
+	return
+}
+
+// ScrapeOffers returns the offer of every shop listed on the product page
+func (s VatgiaScraper) ScrapeOffers(ctx context.Context, item models.Item) (offers []models.ItemOffer, err error) {
+	sanitized, err := url.Parse(item.URL)
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL provided")
+		return
+	}
+
+	doc, err := GetDocumentContext(ctx, s.Fetcher, sanitized)
+
+	if err != nil {
+		return
+	}
+
+	doc.Find(vatgiaOffer).Each(func(i int, sel *goquery.Selection) {
+		offer := models.ItemOffer{
+			Seller:    strings.TrimSpace(sel.Find(vatgiaSeller).First().Text()),
+			Price:     parsePrice(sel.Find(vatgiaPrice).First().Text(), item.Currency),
+			Available: sel.Find(vatgiaSoldOut).Length() == 0,
+		}
+
+		// Outbound links are relative to the product page
+		if href, exists := sel.Find(vatgiaLink).First().Attr("href"); exists {
+			if link, err := sanitized.Parse(href); err == nil {
+				offer.URL = link.String()
+			}
+		}
+
+		if offer.Seller != "" && offer.Price > 0 {
+			offers = append(offers, offer)
+		}
+	})
+
+	if len(offers) == 0 {
+		err = errors.New(fmt.Sprintf("Cannot parse offers for Vatgia with url %s", item.URL))
+	}
+	return
+}
+
+// GetHost returns the host name for the scraper
+func (s VatgiaScraper) GetHost() (host string) {
+	host = "vatgia.com"
+	return
+}
+
+// ProductID returns the store's stable id for the product at path
+func (s VatgiaScraper) ProductID(path *url.URL) string {
+	if match := vatgiaProductID.FindStringSubmatch(path.Path); match != nil {
+		return match[1]
+	}
+	return ""
+}
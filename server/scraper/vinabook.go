@@ -1 +1,130 @@
 package scraper
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+)
+
+// vinabookPrice is the visible price element used when the page has no structured data
+const vinabookPrice = "span[id^=\"sec_discounted_price\"], .price-num"
+
+// isbnText matches an ISBN written in the details of a book page: exactly 13 or 10 digits,
+// with hyphens only between them, so the numbers that follow it (e.g. the page count) aren't captured
+var isbnText = regexp.MustCompile(`(?i)ISBN(?:-1[03])?\s*:?\s*([0-9](?:-?[0-9]){12}|[0-9](?:-?[0-9]){8}-?[0-9X])(?:[^0-9X\-]|$)`)
+
+// VinabookScraper holds the Fetcher for the methods that implements Scraper for Vinabook
+type VinabookScraper struct {
+	Fetcher Fetcher
+}
+
+// ScrapeInfo reads the book from the page's schema.org data, with its ISBN as the GTIN
+func (s VinabookScraper) ScrapeInfo(path *url.URL) (item models.Item, err error) {
+	doc, err := GetDocument(s.Fetcher, path)
+
+	if err != nil {
+		return
+	}
+
+	item, err = parseSchemaInfo(doc, path)
+
+	if err != nil {
+		return
+	}
+
+	// ISBN
+	item.GTIN = findISBN(doc)
+	return
+}
+
+// ScrapePrice returns the current price for an item
+func (s VinabookScraper) ScrapePrice(item models.Item) (itemPrice models.ItemPrice, err error) {
+	return scrapeSchemaPrice(s.Fetcher, item, vinabookPrice)
+}
+
+// GetHost returns the host name for the scraper
+func (s VinabookScraper) GetHost() (host string) {
+	host = "www.vinabook.com"
+	return
+}
+
+// findISBN looks for the book's ISBN in the structured data, then in the details table.
+// It returns the ISBN-13 of the book, or an empty string if no valid ISBN is found.
+func findISBN(doc *goquery.Document) string {
+	candidates := []string{}
+
+	if product, ok := findLDProduct(doc); ok {
+		candidates = append(candidates, product.ISBN, product.GTIN13)
+	}
+
+	if isbn, exists := doc.Find("[itemprop=\"isbn\"]").Attr("content"); exists {
+		candidates = append(candidates, isbn)
+	}
+	candidates = append(candidates, doc.Find("[itemprop=\"isbn\"]").First().Text())
+
+	if match := isbnText.FindStringSubmatch(doc.Find("body").Text()); match != nil {
+		candidates = append(candidates, match[1])
+	}
+
+	for _, candidate := range candidates {
+		if isbn := normalizeISBN(candidate); isbn != "" {
+			return isbn
+		}
+	}
+	return ""
+}
+
+// normalizeISBN validates an ISBN-10 or ISBN-13 and returns it as an ISBN-13 without separators.
+// It returns an empty string if the ISBN is invalid.
+func normalizeISBN(isbn string) string {
+	isbn = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+
+	switch len(isbn) {
+	case 10:
+		sum := 0
+		for i, r := range isbn {
+			var digit int
+			switch {
+			case r >= '0' && r <= '9':
+				digit = int(r - '0')
+			case r == 'X' && i == 9:
+				digit = 10
+			default:
+				return ""
+			}
+			sum += digit * (10 - i)
+		}
+		if sum%11 != 0 {
+			return ""
+		}
+		isbn = "978" + isbn[:9]
+		return isbn + string(rune('0'+ean13CheckDigit(isbn)))
+	case 13:
+		for _, r := range isbn {
+			if r < '0' || r > '9' {
+				return ""
+			}
+		}
+		if ean13CheckDigit(isbn[:12]) != int(isbn[12]-'0') {
+			return ""
+		}
+		return isbn
+	}
+	return ""
+}
+
+// ean13CheckDigit computes the check digit for the first 12 digits of an EAN-13
+func ean13CheckDigit(digits string) int {
+	sum := 0
+	for i, r := range digits[:12] {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(r-'0') * weight
+	}
+	return (10 - sum%10) % 10
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Alert is a subscription whose target price is reached
+type Alert struct {
+	models.Subscription
+	Price     int64
+	Temporary bool      // the price only lasts until the end of a promotion
+	Until     time.Time // end of the promotion, for temporary prices
+}
+
+// Alerts returns the alerts for the subscriptions of an item whose target price is reached.
+// Subscriptions restricted to official sellers are checked against the cheapest available offer of an official seller,
+// the others against the item's latest price, or its running promotion if that is cheaper.
+func Alerts(itemID uuid.UUID) (alerts []Alert, err error) {
+	subscriptions, err := models.LayerInstance().Subscription.GetByItem(itemID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not get the subscriptions for item %s", itemID)
+		return
+	}
+
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	itemPrices, err := models.LayerInstance().ItemPrice.GetAllPrices(itemID, "")
+	if err != nil {
+		err = errors.Wrapf(err, "Could not find the current price for item %s", itemID)
+		return
+	}
+
+	offers, err := models.LayerInstance().ItemOffer.GetOffers(itemID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not find the current offers for item %s", itemID)
+		return
+	}
+
+	promotion, onPromotion, err := models.LayerInstance().Promotion.GetActive(itemID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not find the current promotion for item %s", itemID)
+		return
+	}
+
+	var price int64
+	if len(itemPrices) > 0 && itemPrices[0].Available {
+		price = itemPrices[0].Price
+	}
+	if onPromotion && (price == 0 || promotion.Price < price) {
+		price = promotion.Price
+	}
+	officialPrice := bestOfficialPrice(offers)
+
+	for _, subscription := range subscriptions {
+		alert := Alert{Subscription: subscription, Price: price}
+		if subscription.OfficialOnly {
+			// Promotions are scraped for the page's seller, which may not be official
+			alert.Price = officialPrice
+		} else if onPromotion && price == promotion.Price {
+			// The drop is temporary unless the regular price also reaches the target
+			alert.Temporary = promotion.RegularPrice == 0 || promotion.RegularPrice > subscription.TargetPrice
+			alert.Until = promotion.EndsAt
+		}
+
+		if alert.Price > 0 && alert.Price <= subscription.TargetPrice {
+			alerts = append(alerts, alert)
+		}
+	}
+	return
+}
+
+// bestOfficialPrice returns the price of the cheapest available offer of an official seller, or 0 if there is none
+func bestOfficialPrice(offers []models.ItemOffer) (price int64) {
+	for _, offer := range offers {
+		if !offer.Official || !offer.Available {
+			continue
+		}
+
+		if price == 0 || offer.Price < price {
+			price = offer.Price
+		}
+	}
+	return
+}
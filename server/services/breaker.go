@@ -0,0 +1,123 @@
+package services
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+)
+
+// States of a host's circuit breaker
+const (
+	BreakerClosed   = "closed"    // the host is scraped normally
+	BreakerOpen     = "open"      // the host's items are skipped until the cool-down ends
+	BreakerHalfOpen = "half-open" // a single item is scraped to probe the host
+)
+
+// CircuitBreaker pauses the scraping of hosts that keep failing, so a store that blocks us isn't hit again
+// for every item. A host's breaker opens after threshold consecutive failures, and half-opens after cooldown
+// to let a single probe through. A successful probe closes it, a failed one opens it again.
+type CircuitBreaker struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	hosts     map[string]*hostBreaker
+	now       func() time.Time // clock of the cool-down, replaced in tests
+}
+
+// hostBreaker is the state of the breaker for a single host
+type hostBreaker struct {
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker creates a CircuitBreaker with every host closed
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, hosts: make(map[string]*hostBreaker), now: time.Now}
+}
+
+// Singleton reference to the breaker shared by every update.
+var breaker *CircuitBreaker
+
+// Lock for running only once.
+var breakerOnce sync.Once
+
+// Breaker returns the CircuitBreaker configured from the environment
+func Breaker() *CircuitBreaker {
+	breakerOnce.Do(func() {
+		threshold, _ := strconv.Atoi(utils.BreakerThreshold)
+		cooldown, _ := strconv.Atoi(utils.BreakerCooldown)
+		breaker = NewCircuitBreaker(threshold, time.Duration(cooldown)*time.Minute)
+	})
+	return breaker
+}
+
+// host returns the breaker of a host. The mutex must be held.
+func (b *CircuitBreaker) host(host string) *hostBreaker {
+	hb, ok := b.hosts[host]
+	if !ok {
+		hb = &hostBreaker{state: BreakerClosed}
+		b.hosts[host] = hb
+	}
+	return hb
+}
+
+// Allow checks if an item of host may be scraped now.
+// Once the cool-down of an open breaker ends, it half-opens and allows a single probe.
+func (b *CircuitBreaker) Allow(host string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	hb := b.host(host)
+	switch hb.state {
+	case BreakerOpen:
+		if b.now().Sub(hb.openedAt) < b.cooldown {
+			return false
+		}
+		hb.state = BreakerHalfOpen
+		utils.Sugar.Infof("Circuit breaker for %s is half-open, probing with one item", host)
+		return true
+	case BreakerHalfOpen:
+		// The probe is still running
+		return false
+	}
+	return true
+}
+
+// Record updates the breaker of host with the outcome of a scrape
+func (b *CircuitBreaker) Record(host string, success bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	hb := b.host(host)
+	if success {
+		if hb.state != BreakerClosed {
+			utils.Sugar.Infof("Circuit breaker for %s is closed", host)
+		}
+		hb.state = BreakerClosed
+		hb.failures = 0
+		return
+	}
+
+	hb.failures++
+	if hb.state == BreakerHalfOpen || hb.failures >= b.threshold {
+		if hb.state != BreakerOpen {
+			utils.Sugar.Infof("Circuit breaker for %s is open after %d consecutive failures", host, hb.failures)
+		}
+		hb.state = BreakerOpen
+		hb.openedAt = b.now()
+	}
+}
+
+// State returns the state of the breaker of host
+func (b *CircuitBreaker) State(host string) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.host(host).state
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// breakerStep is an action on the breaker of a test host, followed by the state it should be in
+type breakerStep struct {
+	advance time.Duration // moves the clock forward before the action
+	action  string        // "allow", "success" or "failure"
+	allowed bool          // expected result of "allow"
+	state   string        // expected state after the action
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	const host = "tiki.vn"
+	cooldown := 10 * time.Minute
+
+	cases := []struct {
+		name      string
+		threshold int
+		steps     []breakerStep
+	}{
+		{
+			name:      "closed allows scrapes",
+			threshold: 3,
+			steps: []breakerStep{
+				{action: "allow", allowed: true, state: BreakerClosed},
+				{action: "success", state: BreakerClosed},
+				{action: "allow", allowed: true, state: BreakerClosed},
+			},
+		},
+		{
+			name:      "stays closed under the threshold",
+			threshold: 3,
+			steps: []breakerStep{
+				{action: "failure", state: BreakerClosed},
+				{action: "failure", state: BreakerClosed},
+				{action: "allow", allowed: true, state: BreakerClosed},
+			},
+		},
+		{
+			name:      "success resets the failures",
+			threshold: 3,
+			steps: []breakerStep{
+				{action: "failure", state: BreakerClosed},
+				{action: "failure", state: BreakerClosed},
+				{action: "success", state: BreakerClosed},
+				{action: "failure", state: BreakerClosed},
+				{action: "failure", state: BreakerClosed},
+				{action: "allow", allowed: true, state: BreakerClosed},
+			},
+		},
+		{
+			name:      "opens at the threshold",
+			threshold: 3,
+			steps: []breakerStep{
+				{action: "failure", state: BreakerClosed},
+				{action: "failure", state: BreakerClosed},
+				{action: "failure", state: BreakerOpen},
+				{action: "allow", allowed: false, state: BreakerOpen},
+				{advance: cooldown - time.Second, action: "allow", allowed: false, state: BreakerOpen},
+			},
+		},
+		{
+			name:      "half-opens after the cool-down for a single probe",
+			threshold: 1,
+			steps: []breakerStep{
+				{action: "failure", state: BreakerOpen},
+				{advance: cooldown, action: "allow", allowed: true, state: BreakerHalfOpen},
+				{action: "allow", allowed: false, state: BreakerHalfOpen},
+			},
+		},
+		{
+			name:      "successful probe closes",
+			threshold: 1,
+			steps: []breakerStep{
+				{action: "failure", state: BreakerOpen},
+				{advance: cooldown, action: "allow", allowed: true, state: BreakerHalfOpen},
+				{action: "success", state: BreakerClosed},
+				{action: "allow", allowed: true, state: BreakerClosed},
+			},
+		},
+		{
+			name:      "failed probe opens again for another cool-down",
+			threshold: 3,
+			steps: []breakerStep{
+				{action: "failure", state: BreakerClosed},
+				{action: "failure", state: BreakerClosed},
+				{action: "failure", state: BreakerOpen},
+				{advance: cooldown, action: "allow", allowed: true, state: BreakerHalfOpen},
+				{action: "failure", state: BreakerOpen},
+				{advance: cooldown / 2, action: "allow", allowed: false, state: BreakerOpen},
+				{advance: cooldown / 2, action: "allow", allowed: true, state: BreakerHalfOpen},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			b := NewCircuitBreaker(c.threshold, cooldown)
+			b.now = func() time.Time { return now }
+
+			for i, step := range c.steps {
+				now = now.Add(step.advance)
+				switch step.action {
+				case "allow":
+					if allowed := b.Allow(host); allowed != step.allowed {
+						t.Errorf("step %d: Allow = %v, want %v", i, allowed, step.allowed)
+					}
+				case "success":
+					b.Record(host, true)
+				case "failure":
+					b.Record(host, false)
+				}
+
+				if state := b.State(host); state != step.state {
+					t.Errorf("step %d: state = %s, want %s", i, state, step.state)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHosts(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Minute)
+	b.Record("tiki.vn", false)
+
+	if b.Allow("tiki.vn") {
+		t.Errorf("Allow(tiki.vn) = true, want false")
+	}
+	if !b.Allow("lazada.vn") {
+		t.Errorf("Allow(lazada.vn) = false, want true")
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// maxImageHashFailures is the number of times the image of an item is tried before it isn't hashed at startup anymore,
+// so an image that is gone isn't downloaded at every boot
+const maxImageHashFailures = 3
+
+// UpdateImageHash computes the perceptual hash of an item's image and saves it,
+// so the item can be suggested as a duplicate of the items of other stores with the same image
+func UpdateImageHash(ctx context.Context, item models.Item) (err error) {
+	if item.ImageURL == "" {
+		return
+	}
+
+	hash, err := scraper.ImageHash(ctx, scraper.Instance().Fetcher, item.ImageURL)
+	if err != nil {
+		if e := models.LayerInstance().Item.AddImageHashFailure(item.ID); e != nil {
+			utils.Sugar.Infof("%s", e)
+		}
+		err = errors.Wrapf(err, "Could not hash the image of item %s", item.ID)
+		return
+	}
+
+	// The hash is stored as a bigint, which keeps all of its bits
+	err = models.LayerInstance().Item.SetImageHash(item.ID, int64(hash))
+	if err != nil {
+		err = errors.Wrapf(err, "Could not save the image hash of item %s", item.ID)
+	}
+	return
+}
+
+// HashInBackground hashes the image of an item without blocking, for requests that can't wait for the download.
+// Failures are logged and counted, HashAll tries the item again at the next startups.
+func HashInBackground(item models.Item) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), scrapeDeadline())
+		defer cancel()
+
+		if err := UpdateImageHash(ctx, item); err != nil {
+			utils.Sugar.Infof("%s", err)
+		}
+	}()
+}
+
+// HashAll hashes the image of every item that isn't hashed yet.
+// Items that fail are logged and left for the next run, until they failed maxImageHashFailures times.
+func HashAll() (err error) {
+	items, err := models.LayerInstance().Item.GetUnhashed(maxImageHashFailures)
+	if err != nil {
+		err = errors.Wrap(err, "Could not get the items without an image hash")
+		return
+	}
+
+	for _, item := range items {
+		ctx, cancel := context.WithTimeout(context.Background(), scrapeDeadline())
+		if e := UpdateImageHash(ctx, item); e != nil {
+			utils.Sugar.Errorf("%s", e)
+		}
+		cancel()
+	}
+	return
+}
+
+// DuplicateSuggestions returns the items of other stores with near-identical images,
+// for itemID, or for every item if itemID is uuid.Nil
+func DuplicateSuggestions(itemID uuid.UUID) (suggestions []models.DuplicateSuggestion, err error) {
+	maxDistance, _ := strconv.Atoi(utils.DuplicateDistance)
+
+	if itemID == uuid.Nil {
+		suggestions, err = models.LayerInstance().Duplicate.GetSuggestions(maxDistance)
+	} else {
+		suggestions, err = models.LayerInstance().Duplicate.GetSuggestionsFor(itemID, maxDistance)
+	}
+
+	if err != nil {
+		err = errors.Wrap(err, "Could not get the duplicate suggestions")
+	}
+	return
+}
+
+// DecideDuplicate saves that a user confirmed or rejected that two items are the same product.
+// Confirmed items are grouped into the same product. A rejected item is taken out of the other item's product.
+func DecideDuplicate(itemID uuid.UUID, duplicateID uuid.UUID, confirmed bool, userID uuid.UUID) (err error) {
+	item, err := models.LayerInstance().Item.GetByID(itemID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not find item %s", itemID)
+		return
+	}
+
+	duplicate, err := models.LayerInstance().Item.GetByID(duplicateID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not find item %s", duplicateID)
+		return
+	}
+
+	_, err = models.LayerInstance().Duplicate.Upsert(models.ItemDuplicate{ItemID: itemID, DuplicateID: duplicateID, Confirmed: confirmed, DecidedBy: &userID})
+	if err != nil {
+		err = errors.Wrapf(err, "Could not save the decision on items %s and %s", itemID, duplicateID)
+		return
+	}
+
+	if confirmed {
+		return mergeProducts(item, duplicate)
+	}
+
+	if item.ProductID != nil && duplicate.ProductID != nil && *item.ProductID == *duplicate.ProductID {
+		return splitProduct(duplicate)
+	}
+	return
+}
+
+// mergeProducts groups the products of two items into the product of the first item
+func mergeProducts(item models.Item, duplicate models.Item) (err error) {
+	product, err := productOf(item)
+	if err != nil {
+		return
+	}
+
+	if duplicate.ProductID == nil {
+		err = models.LayerInstance().Item.SetProduct(duplicate.ID, product.ID)
+		if err != nil {
+			err = errors.Wrapf(err, "Could not link item %s to product %s", duplicate.ID, product.ID)
+		}
+		return
+	}
+
+	if *duplicate.ProductID == product.ID {
+		return
+	}
+
+	// Keep the identifiers of the merged product
+	merged, err := models.LayerInstance().Product.GetByID(*duplicate.ProductID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not find product %s", *duplicate.ProductID)
+		return
+	}
+
+	err = models.LayerInstance().Product.AddIdentifiers(product.ID, merged.GTIN, merged.Model)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not save the identifiers of product %s", product.ID)
+		return
+	}
+
+	err = models.LayerInstance().Product.Merge(merged.ID, product.ID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not merge product %s into product %s", merged.ID, product.ID)
+	}
+	return
+}
+
+// splitProduct moves an item into a new product of its own
+func splitProduct(item models.Item) (err error) {
+	product, err := models.LayerInstance().Product.Insert(models.Product{Name: item.Name, GTIN: item.GTIN, Model: item.Model})
+	if err != nil {
+		err = errors.Wrapf(err, "Could not create the product of item %s", item.ID)
+		return
+	}
+
+	err = models.LayerInstance().Item.SetProduct(item.ID, product.ID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not link item %s to product %s", item.ID, product.ID)
+	}
+	return
+}
+
+// productOf returns the product of an item, grouping the item first if it has none
+func productOf(item models.Item) (product models.Product, err error) {
+	if item.ProductID == nil {
+		return GroupItem(item)
+	}
+
+	product, err = models.LayerInstance().Product.GetByID(*item.ProductID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not find product %s", *item.ProductID)
+	}
+	return
+}
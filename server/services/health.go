@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/pkg/errors"
+)
+
+// scraperHost returns the host of the scraper for an item.
+// Items of unknown stores use the host of their URL, as the generic scraper handles every host.
+func scraperHost(s scraper.Scraper, item models.Item) (host string) {
+	host = s.GetHost()
+	if host == "" {
+		if path, err := url.Parse(item.URL); err == nil {
+			host = path.Host
+		}
+	}
+	return
+}
+
+// itemHost returns the host of the scraper for an item
+func itemHost(item models.Item) string {
+	path, err := url.Parse(item.URL)
+	if err != nil {
+		return ""
+	}
+
+	s, _ := scraper.Instance().Get(path.Host)
+	return scraperHost(s, item)
+}
+
+// RecordScrape saves the outcome of scraping the price of an item, so the health of every store can be tracked.
+// Failing to save it is only logged, as it must not fail the update.
+func RecordScrape(s scraper.Scraper, item models.Item, itemPrice models.ItemPrice, scrapeErr error) {
+	// A scrape cancelled by the client says nothing about the store
+	if errors.Is(scrapeErr, context.Canceled) {
+		return
+	}
+
+	result := models.ScrapeResult{
+		Host:     scraperHost(s, item),
+		ItemID:   item.ID,
+		Outcome:  scraper.Outcome(scrapeErr),
+		Strategy: itemPrice.Strategy,
+	}
+	if scrapeErr != nil {
+		result.Error = scrapeErr.Error()
+		result.Strategy = ""
+	}
+
+	_, err := models.LayerInstance().ScrapeResult.Insert(result)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not record the scrape of item with url %s", item.URL)
+		utils.Sugar.Error(err)
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"strconv"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// GroupItem links an item to the product it is a listing of, so the same product sold by several stores is compared.
+// The product is found by the item's GTIN or model number, then by the similarity of the names.
+// A new product is created for the item if none matches.
+func GroupItem(item models.Item) (product models.Product, err error) {
+	if item.GTIN != "" || item.Model != "" {
+		product, err = models.LayerInstance().Product.GetByIdentifiers(item.GTIN, item.Model)
+		if err != nil {
+			err = errors.Wrapf(err, "Could not find the product of item %s", item.ID)
+			return
+		}
+	}
+
+	if product.ID == uuid.Nil {
+		minSimilarity, _ := strconv.ParseFloat(utils.ProductMatchSimilarity, 64)
+
+		var match models.ProductMatch
+		var found bool
+		match, found, err = models.LayerInstance().Product.GetSimilar(item.Name, item.GTIN, item.Model, minSimilarity)
+		if err != nil {
+			err = errors.Wrapf(err, "Could not find a product similar to item %s", item.ID)
+			return
+		}
+
+		if found {
+			product = match.Product
+			utils.Sugar.Infof("Grouping item %s into product %s, with a name similarity of %.2f", item.ID, product.ID, match.Similarity)
+		}
+	}
+
+	if product.ID == uuid.Nil {
+		product, err = models.LayerInstance().Product.Insert(models.Product{Name: item.Name, GTIN: item.GTIN, Model: item.Model})
+		if err != nil {
+			err = errors.Wrapf(err, "Could not create the product of item %s", item.ID)
+			return
+		}
+	} else if (product.GTIN == "" && item.GTIN != "") || (product.Model == "" && item.Model != "") {
+		// The identifiers of the item let the next items of the product match without their names
+		err = models.LayerInstance().Product.AddIdentifiers(product.ID, item.GTIN, item.Model)
+		if err != nil {
+			err = errors.Wrapf(err, "Could not save the identifiers of product %s", product.ID)
+			return
+		}
+	}
+
+	err = models.LayerInstance().Item.SetProduct(item.ID, product.ID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not link item %s to product %s", item.ID, product.ID)
+	}
+	return
+}
+
+// GroupAll links every item that isn't grouped yet to its product.
+// Items that fail are logged and left for the next run.
+func GroupAll() (err error) {
+	items, err := models.LayerInstance().Item.GetUngrouped()
+	if err != nil {
+		err = errors.Wrap(err, "Could not get the items without a product")
+		return
+	}
+
+	for _, item := range items {
+		if _, e := GroupItem(item); e != nil {
+			utils.Sugar.Errorf("%s", e)
+		}
+	}
+	return
+}
+
+// Listing is an item of a product with the price it can be bought at now
+type Listing struct {
+	models.ItemWithPrice
+	Price     int64 // the running promotion's price, or the latest price
+	BasePrice int64 // Price in BaseCurrency, to compare the items of different currencies
+}
+
+// CheapestListing returns the available item of a product that is the cheapest now, counting running promotions.
+// Stale items are skipped, their last price may not be offered anymore.
+// Prices in other currencies are compared with rates, items whose currency has no rate are skipped.
+// found is false if no item is available.
+func CheapestListing(items []models.ItemWithPrice, rates ExchangeRates) (cheapest Listing, found bool) {
+	for _, item := range items {
+		if item.ItemPrice == nil || item.Item == nil || item.Stale || !item.Available || item.ItemPrice.Price == 0 {
+			continue
+		}
+
+		listing := Listing{ItemWithPrice: item, Price: item.ItemPrice.Price}
+		if item.PromoPrice != nil && *item.PromoPrice < listing.Price {
+			listing.Price = *item.PromoPrice
+		}
+
+		var err error
+		listing.BasePrice, err = rates.Convert(listing.Price, item.Currency, BaseCurrency)
+		if err != nil {
+			utils.Sugar.Infof("Cannot compare the price of item %s: %s", item.Item.ID, err)
+			continue
+		}
+
+		if !found || listing.BasePrice < cheapest.BasePrice {
+			cheapest, found = listing, true
+		}
+	}
+	return
+}
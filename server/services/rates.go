@@ -0,0 +1,195 @@
+package services
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/UN0wen/pricewatch-vn/server/api/models"
+	"github.com/UN0wen/pricewatch-vn/server/scraper"
+	"github.com/UN0wen/pricewatch-vn/server/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// BaseCurrency is the currency the exchange rates are stored in
+const BaseCurrency = "VND"
+
+// RateProvider is a source of exchange rates.
+// rates maps every currency to the value of one of its units in BaseCurrency.
+type RateProvider interface {
+	Rates() (rates map[string]float64, err error)
+}
+
+// rateTable is the format of the rate files and of the rate APIs:
+// every rate is the number of units of the currency worth one unit of Base.
+// YAML is a superset of JSON, so it reads both.
+type rateTable struct {
+	Base  string             `yaml:"base"`
+	Rates map[string]float64 `yaml:"rates"`
+}
+
+// toBase converts the table to the values of one unit of every currency in BaseCurrency
+func (table rateTable) toBase() (rates map[string]float64, err error) {
+	base := strings.ToUpper(table.Base)
+	if base == "" {
+		base = BaseCurrency
+	}
+
+	perBase := make(map[string]float64)
+	for currency, rate := range table.Rates {
+		perBase[strings.ToUpper(currency)] = rate
+	}
+	perBase[base] = 1
+
+	// Units of BaseCurrency worth one unit of base
+	baseRate, ok := perBase[BaseCurrency]
+	if !ok || baseRate <= 0 {
+		err = errors.New(fmt.Sprintf("The exchange rates have no rate for %s", BaseCurrency))
+		return
+	}
+
+	rates = make(map[string]float64)
+	for currency, rate := range perBase {
+		if rate <= 0 {
+			continue
+		}
+		rates[currency] = baseRate / rate
+	}
+	return
+}
+
+// FileRateProvider reads the exchange rates from a YAML or JSON file, for offline use
+type FileRateProvider struct {
+	Path string
+}
+
+// Rates returns the exchange rates in the file
+func (p FileRateProvider) Rates() (rates map[string]float64, err error) {
+	data, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot read the exchange rate file %s", p.Path)
+		return
+	}
+
+	var table rateTable
+	err = yaml.Unmarshal(data, &table)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot parse the exchange rate file %s", p.Path)
+		return
+	}
+	return table.toBase()
+}
+
+// HTTPRateProvider reads the exchange rates from a JSON API,
+// e.g. one returning {"base": "USD", "rates": {"VND": 25400, ...}}
+type HTTPRateProvider struct {
+	URL    string
+	Client *http.Client
+}
+
+// Rates returns the exchange rates served by the API
+func (p HTTPRateProvider) Rates() (rates map[string]float64, err error) {
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	resp, err := client.Get(p.URL)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot get the exchange rates from %s", p.URL)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = errors.New(fmt.Sprintf("The exchange rate API responded with %s", resp.Status))
+		return
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot read the exchange rates from %s", p.URL)
+		return
+	}
+
+	var table rateTable
+	err = yaml.Unmarshal(data, &table)
+	if err != nil {
+		err = errors.Wrapf(err, "Cannot parse the exchange rates from %s", p.URL)
+		return
+	}
+	return table.toBase()
+}
+
+// NewRateProvider returns the RateProvider configured from the environment
+func NewRateProvider() (provider RateProvider, err error) {
+	switch utils.RateProvider {
+	case "file":
+		provider = FileRateProvider{Path: utils.RateFile}
+	case "http":
+		provider = HTTPRateProvider{URL: utils.RateURL}
+	default:
+		err = errors.New(fmt.Sprintf("Unknown exchange rate provider %s", utils.RateProvider))
+	}
+	return
+}
+
+// UpdateRates gets the exchange rates from provider and saves them
+func UpdateRates(provider RateProvider) (err error) {
+	rates, err := provider.Rates()
+	if err != nil {
+		return
+	}
+
+	for currency, rate := range rates {
+		_, err = models.LayerInstance().ExchangeRate.Upsert(models.ExchangeRate{Currency: currency, Rate: rate})
+		if err != nil {
+			err = errors.Wrapf(err, "Could not save the exchange rate of %s", currency)
+			return
+		}
+	}
+	return
+}
+
+// ExchangeRates converts prices between currencies.
+// It maps every currency to the value of one of its units in BaseCurrency.
+type ExchangeRates map[string]float64
+
+// LoadRates gets the saved exchange rates
+func LoadRates() (rates ExchangeRates, err error) {
+	saved, err := models.LayerInstance().ExchangeRate.GetAll()
+	if err != nil {
+		err = errors.Wrap(err, "Could not get the exchange rates")
+		return
+	}
+
+	rates = ExchangeRates{BaseCurrency: 1}
+	for _, rate := range saved {
+		rates[rate.Currency] = rate.Rate
+	}
+	return
+}
+
+// Convert converts an amount in the minor unit of a currency to the minor unit of another, rounded to the nearest.
+// scraper.MinorUnits tells the minor unit of every currency, e.g. cents for USD and đồng for VND.
+func (rates ExchangeRates) Convert(amount int64, from string, to string) (converted int64, err error) {
+	fromRate, ok := rates[strings.ToUpper(from)]
+	if !ok {
+		err = errors.New(fmt.Sprintf("No exchange rate for %s", from))
+		return
+	}
+
+	toRate, ok := rates[strings.ToUpper(to)]
+	if !ok {
+		err = errors.New(fmt.Sprintf("No exchange rate for %s", to))
+		return
+	}
+
+	value := float64(amount) / math.Pow10(scraper.MinorUnits(from)) * fromRate / toRate
+	converted = int64(math.Round(value * math.Pow10(scraper.MinorUnits(to))))
+	return
+}
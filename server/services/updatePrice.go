@@ -1,8 +1,11 @@
 package services
 
 import (
+	"context"
 	"net/url"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/UN0wen/pricewatch-vn/server/api/models"
 	"github.com/UN0wen/pricewatch-vn/server/scraper"
@@ -26,27 +29,89 @@ const (
 type result struct {
 	itemID      uuid.UUID
 	priceChange int
+	offerChange bool // the price or availability of one of the item's offers changed
+	stale       bool // the item was skipped because its host's circuit breaker is open
 	err         error
 }
 
-// UpdateOne takes an item, then scrapes the URL and return an updated variable
-func UpdateOne(item models.Item) (updated int, err error) {
+// UpdateOne takes an item, then scrapes the URL and return an updated variable.
+// offersChanged is true if an offer was recorded because its price or availability changed.
+// ctx cancels the scrape of the item's price.
+func UpdateOne(ctx context.Context, item models.Item) (updated int, offersChanged bool, err error) {
+	// The price, offers, variants and promotions are read from a single download of the item's page
+	ctx = scraper.WithDownloads(ctx)
+
 	path, err := url.Parse(item.URL)
-	s := scraper.Instance().Scrapers[path.Host]
+	if err != nil {
+		err = errors.Wrapf(err, "Invalid URL for item %s", item.ID)
+		return
+	}
+	s, _ := scraper.Instance().Get(path.Host)
 
-	oldItemPrices, err := models.LayerInstance().ItemPrice.GetAllPrices(item.ID)
+	oldItemPrices, err := models.LayerInstance().ItemPrice.GetAllPrices(item.ID, "")
 
 	if err != nil {
 		err = errors.Wrapf(err, "Could not find the current price for item with url %s", item.URL)
 		return
 	}
 
-	itemPrice, err := s.ScrapePrice(item)
+	itemPrice, offers, err := scraper.ScrapeListing(ctx, s, item)
+	RecordScrape(s, item, itemPrice, err)
+
 	if err != nil {
 		err = errors.Wrapf(err, "Could not scrape the price for item with url %s", item.URL)
 		return
 	}
 
+	if item.Stale {
+		err = models.LayerInstance().Item.SetStale(item.ID, false)
+		if err != nil {
+			err = errors.Wrapf(err, "Could not mark item with url %s as up to date", item.URL)
+			return
+		}
+	}
+
+	oldOffers, err := models.LayerInstance().ItemOffer.GetOffers(item.ID)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not find the current offers for item with url %s", item.URL)
+		return
+	}
+
+	latest := make(map[string]models.ItemOffer)
+	for _, offer := range oldOffers {
+		latest[offer.Seller] = offer
+	}
+
+	// Sellers that left the listing are closed with an unavailable offer, so their last price isn't current anymore.
+	// Nothing is closed if the offers couldn't be read.
+	seen := make(map[string]bool)
+	for _, offer := range offers {
+		seen[offer.Seller] = true
+	}
+	for seller, old := range latest {
+		if len(offers) > 0 && !seen[seller] && old.Available {
+			old.Available = false
+			offers = append(offers, old)
+		}
+	}
+
+	// Every offer keeps its own price series, even if the best price is unchanged.
+	// An offer is only recorded when its price or availability changed.
+	for _, offer := range offers {
+		if old, ok := latest[offer.Seller]; ok && old.Price == offer.Price && old.Available == offer.Available {
+			continue
+		}
+
+		offer.ItemID = item.ID
+		_, err = models.LayerInstance().ItemOffer.Insert(offer)
+
+		if err != nil {
+			err = errors.Wrapf(err, "Could not insert new offer for item with url %s", item.URL)
+			return
+		}
+		offersChanged = true
+	}
+
 	if len(oldItemPrices) == 0 {
 		updated = PriceRise
 	} else {
@@ -61,6 +126,15 @@ func UpdateOne(item models.Item) (updated int, err error) {
 		}
 	}
 
+	// The price was read, so a store that fails to return its variants or promotions doesn't fail the update
+	if e := UpdateVariants(ctx, s, item); e != nil {
+		utils.Sugar.Infof("%s", e)
+	}
+
+	if e := UpdatePromotions(ctx, s, item); e != nil {
+		utils.Sugar.Infof("%s", e)
+	}
+
 	utils.Sugar.Infof("%v", itemPrice)
 	if updated > 0 {
 		itemPrice.ItemID = item.ID
@@ -73,6 +147,76 @@ func UpdateOne(item models.Item) (updated int, err error) {
 	return
 }
 
+// UpdateVariants scrapes the price of every variant of an item and records the prices that changed.
+// It does nothing for scrapers that don't implement scraper.VariantScraper. ctx cancels the scrape.
+func UpdateVariants(ctx context.Context, s scraper.Scraper, item models.Item) (err error) {
+	variantScraper, ok := s.(scraper.VariantScraper)
+	if !ok {
+		return
+	}
+
+	variants, prices, err := variantScraper.ScrapeVariants(ctx, item)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not scrape the variants for item with url %s", item.URL)
+		return
+	}
+
+	for _, variant := range variants {
+		variant.ItemID = item.ID
+		_, err = models.LayerInstance().ItemVariant.Upsert(variant)
+		if err != nil {
+			err = errors.Wrapf(err, "Could not save variant %s for item with url %s", variant.SKU, item.URL)
+			return
+		}
+	}
+
+	for _, itemPrice := range prices {
+		var oldItemPrices []models.ItemPrice
+		oldItemPrices, err = models.LayerInstance().ItemPrice.GetAllPrices(item.ID, itemPrice.SKU)
+		if err != nil {
+			err = errors.Wrapf(err, "Could not find the current price of variant %s for item with url %s", itemPrice.SKU, item.URL)
+			return
+		}
+
+		if len(oldItemPrices) > 0 && oldItemPrices[0].Price == itemPrice.Price {
+			continue
+		}
+
+		itemPrice.ItemID = item.ID
+		_, err = models.LayerInstance().ItemPrice.Insert(itemPrice)
+		if err != nil {
+			err = errors.Wrapf(err, "Could not insert new price of variant %s for item with url %s", itemPrice.SKU, item.URL)
+			return
+		}
+	}
+	return
+}
+
+// UpdatePromotions scrapes the promotions of an item and saves them with their window.
+// It does nothing for scrapers that don't implement scraper.PromotionScraper. ctx cancels the scrape.
+func UpdatePromotions(ctx context.Context, s scraper.Scraper, item models.Item) (err error) {
+	promotionScraper, ok := s.(scraper.PromotionScraper)
+	if !ok {
+		return
+	}
+
+	promotions, err := promotionScraper.ScrapePromotions(ctx, item)
+	if err != nil {
+		err = errors.Wrapf(err, "Could not scrape the promotions for item with url %s", item.URL)
+		return
+	}
+
+	for _, promotion := range promotions {
+		promotion.ItemID = item.ID
+		_, err = models.LayerInstance().Promotion.Upsert(promotion)
+		if err != nil {
+			err = errors.Wrapf(err, "Could not save promotion for item with url %s", item.URL)
+			return
+		}
+	}
+	return
+}
+
 // UpdateAll tries to go over every item in the database and update them
 func UpdateAll() (err error) {
 	items, err := models.LayerInstance().Item.GetAll()
@@ -86,9 +230,16 @@ func UpdateAll() (err error) {
 	var results []result
 	ch := make(chan result)
 
+	// The items of a host are updated one after the other, so its circuit breaker can stop the update
+	itemsByHost := make(map[string][]models.Item)
 	for _, item := range items {
+		host := itemHost(item)
+		itemsByHost[host] = append(itemsByHost[host], item)
+	}
+
+	for host, hostItems := range itemsByHost {
 		wg.Add(1)
-		go produce(ch, &wg, item)
+		go produce(ch, &wg, host, hostItems)
 	}
 
 	go func() {
@@ -103,10 +254,26 @@ func UpdateAll() (err error) {
 	utils.Sugar.Infof("UpdateAll finished with results:")
 
 	for _, res := range results {
+		if res.stale {
+			utils.Sugar.Infof("%s: stale, %s", res.itemID, res.err)
+			continue
+		}
 		utils.Sugar.Infof("%s: %d, %s", res.itemID, res.priceChange, res.err)
-		// Send email
-		if err == nil && res.priceChange == PriceFall {
+		// Send email, an offer that changed can reach an OfficialOnly subscription's target with the same headline price
+		if res.err == nil && (res.priceChange == PriceFall || res.offerChange) {
+			alerts, e := Alerts(res.itemID)
+			if e != nil {
+				utils.Sugar.Infof("%s: %s", res.itemID, e)
+				continue
+			}
 
+			for _, alert := range alerts {
+				if alert.Temporary {
+					utils.Sugar.Infof("%s: price alert for %s at %d, temporary until %s", res.itemID, alert.Email, alert.Price, alert.Until.Format(time.RFC3339))
+					continue
+				}
+				utils.Sugar.Infof("%s: price alert for %s at %d", res.itemID, alert.Email, alert.Price)
+			}
 		}
 	}
 
@@ -115,14 +282,45 @@ func UpdateAll() (err error) {
 
 // concurrency functions
 
-func produce(ch chan result, wg *sync.WaitGroup, item models.Item) {
+func produce(ch chan result, wg *sync.WaitGroup, host string, items []models.Item) {
 	defer wg.Done()
 
-	updated, err := UpdateOne(item)
+	for _, item := range items {
+		if !Breaker().Allow(host) {
+			ch <- result{
+				itemID: item.ID,
+				stale:  true,
+				err:    models.LayerInstance().Item.SetStale(item.ID, true),
+			}
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), scrapeDeadline())
+		updated, offersChanged, err := UpdateOne(ctx, item)
+		cancel()
+		Breaker().Record(host, !isStoreFailure(err))
 
-	ch <- result{
-		itemID:      item.ID,
-		priceChange: updated,
-		err:         err,
+		ch <- result{
+			itemID:      item.ID,
+			priceChange: updated,
+			offerChange: offersChanged,
+			err:         err,
+		}
 	}
 }
+
+// scrapeDeadline returns how long the update of a single item may take
+func scrapeDeadline() time.Duration {
+	seconds, err := strconv.Atoi(utils.ScraperDeadline)
+	if err != nil || seconds <= 0 {
+		seconds = 60
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// isStoreFailure checks if an update failed because the store could not be reached or blocked us.
+// Parse errors don't count, as the store did answer.
+func isStoreFailure(err error) bool {
+	outcome := scraper.Outcome(err)
+	return outcome == models.OutcomeNetwork || outcome == models.OutcomeBlocked
+}
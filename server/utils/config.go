@@ -1,6 +1,9 @@
 package utils
 
-import "os"
+import (
+	"os"
+	"path/filepath"
+)
 
 // GetVar gets an environment variable with name name, and returns its value if its set
 // If not, the function returns the default value
@@ -32,3 +35,76 @@ var DBUrl = GetVar("DATABASE_URL", "")
 
 // ServerPort is the port the server listens on
 var ServerPort = GetVar("PORT", "8080")
+
+// BreakerThreshold is the number of consecutive failures after which the scraping of a store is paused
+var BreakerThreshold = GetVar("BREAKER_THRESHOLD", "5")
+
+// BreakerCooldown is the number of minutes the scraping of a store is paused before it is tried again
+var BreakerCooldown = GetVar("BREAKER_COOLDOWN", "30")
+
+// AdminEmails is the comma separated list of the users allowed to use the admin endpoints
+var AdminEmails = GetVar("ADMIN_EMAILS", "")
+
+// ScraperTimeout is the timeout in seconds of a single request to a store
+var ScraperTimeout = GetVar("SCRAPER_TIMEOUT", "15")
+
+// ScraperRetries is the number of retries of a request to a store after a temporary failure
+var ScraperRetries = GetVar("SCRAPER_RETRIES", "3")
+
+// ScraperBackoff is the delay in milliseconds before the first retry, doubled for every retry
+var ScraperBackoff = GetVar("SCRAPER_BACKOFF", "500")
+
+// ScraperRate is the number of requests per second sent to each store
+var ScraperRate = GetVar("SCRAPER_RATE", "2")
+
+// ScraperBurst is the number of requests each store can receive at once
+var ScraperBurst = GetVar("SCRAPER_BURST", "4")
+
+// ScraperDeadline is the time in seconds the update of a single item may take, including every retry
+var ScraperDeadline = GetVar("SCRAPER_DEADLINE", "60")
+
+// ScraperUserAgent is the User-Agent sent to the stores, the scraper's default if empty.
+// Its first token is the name matched against the stores' robots.txt.
+var ScraperUserAgent = GetVar("SCRAPER_USER_AGENT", "")
+
+// ScraperHeaders are extra headers sent to the stores, as "Name: value" pairs separated by "|",
+// e.g. "Accept-Language: vi-VN|Cookie: region=HN"
+var ScraperHeaders = GetVar("SCRAPER_HEADERS", "")
+
+// ScraperRobots enables following the stores' robots.txt, "true" or "false"
+var ScraperRobots = GetVar("SCRAPER_ROBOTS", "true")
+
+// ScraperRobotsTTL is the time in minutes a store's robots.txt is cached
+var ScraperRobotsTTL = GetVar("SCRAPER_ROBOTS_TTL", "1440")
+
+// ScraperCache enables the cache of recently downloaded pages, "true" or "false"
+var ScraperCache = GetVar("SCRAPER_CACHE", "true")
+
+// ScraperCacheDir is the folder of the cache of recently downloaded pages
+var ScraperCacheDir = GetVar("SCRAPER_CACHE_DIR", filepath.Join(os.TempDir(), "pricewatch-cache"))
+
+// ScraperCacheSize is the number of pages kept in the cache
+var ScraperCacheSize = GetVar("SCRAPER_CACHE_SIZE", "200")
+
+// ScraperCacheFresh is the time in seconds a cached page is used without asking the store if it changed
+var ScraperCacheFresh = GetVar("SCRAPER_CACHE_FRESH", "60")
+
+// ScraperProxy is the URL of an optional HTTP proxy used to reach the stores
+var ScraperProxy = GetVar("SCRAPER_PROXY", "")
+
+// RateProvider is the source of the exchange rates, "file" or "http"
+var RateProvider = GetVar("RATE_PROVIDER", "file")
+
+// RateFile is the file read by the file rate provider
+var RateFile = GetVar("RATE_FILE", "./db/rates.yaml")
+
+// RateURL is the URL of the JSON API read by the http rate provider
+var RateURL = GetVar("RATE_URL", "")
+
+// ProductMatchSimilarity is the minimum similarity, between 0 and 1, of the names of an item and a product
+// for the item to be grouped into the product without a GTIN or model number
+var ProductMatchSimilarity = GetVar("PRODUCT_MATCH_SIMILARITY", "0.6")
+
+// DuplicateDistance is the largest number of bits, out of 64, the image hashes of two items may differ by
+// for the items to be suggested as the same product
+var DuplicateDistance = GetVar("DUPLICATE_DISTANCE", "6")